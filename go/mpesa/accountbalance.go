@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// AcknowledgementResponse is the response the asynchronous APIs send back once they accept a request. The
+// result is posted to the ResultURL of the request later.
+type AcknowledgementResponse struct {
+	ConversationID           string `json:"ConversationID"`
+	OriginatorConversationID string `json:"OriginatorConversationID"`
+	ResponseCode             string `json:"ResponseCode"`
+	ResponseDescription      string `json:"ResponseDescription"`
+	RequestID                string `json:"requestId"`
+	ErrorCode                string `json:"errorCode"`
+	ErrorMessage             string `json:"errorMessage"`
+}
+
+// AccountBalanceRequestBody is the body of a request querying the balances of a shortcode
+type AccountBalanceRequestBody struct {
+	Initiator          string                `json:"Initiator"`
+	SecurityCredential string                `json:"SecurityCredential"`
+	CommandID          AccountBalanceCommand `json:"CommandID"` // Defaults to AccountBalanceQuery
+	PartyA             string                `json:"PartyA"`
+	IdentifierType     string                `json:"IdentifierType"` // "4" for a shortcode
+	Remarks            string                `json:"Remarks"`
+	QueueTimeOutURL    string                `json:"QueueTimeOutURL"`
+	ResultURL          string                `json:"ResultURL"`
+}
+
+// validate checks the account balance request body before it is sent
+func (b *AccountBalanceRequestBody) validate(logger *slog.Logger) error {
+	if err := validateCommand("CommandID", b.CommandID, AccountBalanceQuery); err != nil {
+		return err
+	}
+
+	return validateResultURLs(b.ResultURL, b.QueueTimeOutURL, logger)
+}
+
+// QueryAccountBalance requests the balances of the shortcode in body.PartyA, which are posted to the ResultURL
+// and decoded with AccountBalanceResultCallback. The initiator and security credential configured on the app
+// are used when the body leaves them empty.
+func (m *Mpesa) QueryAccountBalance(body *AccountBalanceRequestBody) (*AcknowledgementResponse, error) {
+	return m.QueryAccountBalanceWithContext(context.Background(), body)
+}
+
+// QueryAccountBalanceWithContext is QueryAccountBalance with a context that cancels the request
+func (m *Mpesa) QueryAccountBalanceWithContext(ctx context.Context, body *AccountBalanceRequestBody) (*AcknowledgementResponse, error) {
+	balanceBody := *body
+	if balanceBody.CommandID == "" {
+		balanceBody.CommandID = AccountBalanceQuery
+	}
+
+	if err := m.fillInitiator(&balanceBody.Initiator, &balanceBody.SecurityCredential); err != nil {
+		return nil, err
+	}
+
+	if err := balanceBody.validate(m.logger); err != nil {
+		return nil, err
+	}
+
+	ack := new(AcknowledgementResponse)
+	if _, err := m.send(ctx, accountBalanceEndpoint, balanceBody, ack); err != nil {
+		return nil, err
+	}
+
+	return ack, nil
+}
+
+// AccountBalanceResultCallback is the result of an account balance query posted to the ResultURL
+type AccountBalanceResultCallback struct {
+	Result ResultEnvelope `json:"Result"`
+}
+
+// AccountBalance is the balance of one of the accounts of a shortcode, such as its working or utility account
+type AccountBalance struct {
+	Name      string
+	Currency  string
+	Available Money
+	Current   Money
+	Reserved  Money
+	Uncleared Money
+}
+
+// Balances parses the AccountBalance result parameter, which Safaricom sends as accounts separated by "&" with
+// their fields separated by "|". It returns nil when the query failed and carries no balances.
+func (c *AccountBalanceResultCallback) Balances() ([]AccountBalance, error) {
+	raw, ok := c.Result.StringParameter("AccountBalance")
+	if !ok || raw == "" {
+		return nil, nil
+	}
+
+	accounts := strings.Split(raw, "&")
+	balances := make([]AccountBalance, 0, len(accounts))
+
+	for _, account := range accounts {
+		fields := strings.Split(account, "|")
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("mpesa: invalid account balance %q", account)
+		}
+
+		balance := AccountBalance{Name: fields[0], Currency: fields[1]}
+
+		amounts := []*Money{&balance.Available, &balance.Current, &balance.Reserved, &balance.Uncleared}
+		for i, amount := range amounts {
+			parsed, err := ParseMoney(fields[i+2])
+			if err != nil {
+				return nil, err
+			}
+
+			*amount = parsed
+		}
+
+		balances = append(balances, balance)
+	}
+
+	return balances, nil
+}
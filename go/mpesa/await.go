@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// STKAwaiter hands the STK push callbacks to the AwaitSTKResult calls waiting for their CheckoutRequestID.
+// Register it on a CallbackRouter with OnSTKCallbackAwaiter. It is safe for concurrent use.
+type STKAwaiter struct {
+	mu      sync.Mutex
+	waiters map[string]chan *STKPushCallbackResponse
+}
+
+// NewSTKAwaiter returns an STKAwaiter without waiters
+func NewSTKAwaiter() *STKAwaiter {
+	return &STKAwaiter{waiters: make(map[string]chan *STKPushCallbackResponse)}
+}
+
+// Deliver hands the callback to the call waiting for its CheckoutRequestID and reports whether there was one.
+// Repeated deliveries of a callback that is already waiting to be picked up are dropped.
+func (a *STKAwaiter) Deliver(callback *STKPushCallbackResponse) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ch, ok := a.waiters[callback.Body.StkCallback.CheckoutRequestID]
+	if !ok {
+		return false
+	}
+
+	select {
+	case ch <- callback:
+	default:
+	}
+
+	return true
+}
+
+// register starts waiting for the callback of checkoutRequestID, until the returned func is called
+func (a *STKAwaiter) register(checkoutRequestID string) (<-chan *STKPushCallbackResponse, func()) {
+	ch := make(chan *STKPushCallbackResponse, 1)
+
+	a.mu.Lock()
+	a.waiters[checkoutRequestID] = ch
+	a.mu.Unlock()
+
+	return ch, func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+
+		if a.waiters[checkoutRequestID] == ch {
+			delete(a.waiters, checkoutRequestID)
+		}
+	}
+}
+
+// stkPoll is the outcome of polling the status of an STK push
+type stkPoll struct {
+	status *STKPushQueryResponse
+	err    error
+}
+
+// AwaitSTKResult waits for the result of the STK push identified by checkoutRequestID, from its callback handed
+// to awaiter or from polling its status with PollSTKPushStatus every pollInterval, whichever comes first. The
+// other one is cancelled, and the registration and polling goroutine are gone once it returns. A failed poll
+// leaves the callback to be waited for until ctx is done, then the poll error is returned.
+func (m *Mpesa) AwaitSTKResult(ctx context.Context, awaiter *STKAwaiter, checkoutRequestID string, pollInterval time.Duration) (*STKResult, error) {
+	callbacks, unregister := awaiter.register(checkoutRequestID)
+	defer unregister()
+
+	pollCtx, cancel := context.WithCancel(ctx)
+	polls := make(chan stkPoll, 1)
+
+	go func() {
+		status, err := m.PollSTKPushStatus(pollCtx, checkoutRequestID, pollInterval)
+		polls <- stkPoll{status, err}
+	}()
+
+	// The poll is stopped and waited for on every return, so that no goroutine outlives the call
+	polled := false
+	defer func() {
+		cancel()
+		if !polled {
+			<-polls
+		}
+	}()
+
+	var pollErr error
+	for {
+		select {
+		case callback := <-callbacks:
+			return &STKResult{Callback: callback}, nil
+		case poll := <-polls:
+			polled = true
+			if poll.err == nil {
+				return &STKResult{Status: poll.status}, nil
+			}
+
+			pollErr = poll.err
+			polls = nil
+		case <-ctx.Done():
+			if pollErr != nil {
+				return nil, pollErr
+			}
+
+			return nil, fmt.Errorf("mpesa: no result for STK push %s: %w", checkoutRequestID, ctx.Err())
+		}
+	}
+}
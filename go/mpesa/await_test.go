@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+// stkProcessingEnvelope is the answer of the status queries of STK pushes the customer has not answered yet
+const stkProcessingEnvelope = `{
+	"requestId": "11728-2929992-1",
+	"errorCode": "500.001.1001",
+	"errorMessage": "The transaction is being processed"
+}`
+
+func TestAwaitSTKResultCleansUp(t *testing.T) {
+	t.Cleanup(func() { goleak.VerifyNone(t) })
+
+	callbackFirst := NewMockServer()
+	defer callbackFirst.Close()
+	callbackFirst.Respond(stkQueryEndpoint.path, http.StatusInternalServerError, stkProcessingEnvelope)
+
+	pollFirst := NewMockServer()
+	defer pollFirst.Close()
+
+	tests := []struct {
+		name     string
+		server   *MockServer
+		callback bool
+	}{
+		{name: "callback first", server: callbackFirst, callback: true},
+		{name: "poll first", server: pollFirst},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMpesa(t, tt.server, MpesaOpts{})
+			awaiter := NewSTKAwaiter()
+
+			for i := 0; i < 100; i++ {
+				checkoutRequestID := fmt.Sprintf("ws_CO_%d", i)
+				if tt.callback {
+					go deliverWhenAwaited(awaiter, checkoutRequestID)
+				}
+
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				result, err := m.AwaitSTKResult(ctx, awaiter, checkoutRequestID, time.Millisecond)
+				cancel()
+
+				if err != nil {
+					t.Fatalf("AwaitSTKResult() error = %v", err)
+				}
+
+				if got := result.Callback != nil; got != tt.callback {
+					t.Fatalf("AwaitSTKResult() = %+v, want the result from the callback %v", result, tt.callback)
+				}
+			}
+
+			if len(awaiter.waiters) != 0 {
+				t.Errorf("%d waiters are still registered", len(awaiter.waiters))
+			}
+		})
+	}
+}
+
+func TestAwaitSTKResultDeadline(t *testing.T) {
+	t.Cleanup(func() { goleak.VerifyNone(t) })
+
+	s := NewMockServer()
+	defer s.Close()
+	s.Respond(stkQueryEndpoint.path, http.StatusInternalServerError, stkProcessingEnvelope)
+
+	m := newTestMpesa(t, s, MpesaOpts{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := m.AwaitSTKResult(ctx, NewSTKAwaiter(), "ws_CO_1", time.Millisecond); err == nil {
+		t.Fatal("AwaitSTKResult() succeeded without a result")
+	}
+}
+
+// deliverWhenAwaited delivers the callback of checkoutRequestID as soon as it is waited for
+func deliverWhenAwaited(awaiter *STKAwaiter, checkoutRequestID string) {
+	callback := new(STKPushCallbackResponse)
+	callback.Body.StkCallback.CheckoutRequestID = checkoutRequestID
+
+	for !awaiter.Deliver(callback) {
+		runtime.Gosched()
+	}
+}
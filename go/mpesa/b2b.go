@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+// B2BRequestBody is the body with the parameters to be used to initiate a B2B payment request
+type B2BRequestBody struct {
+	Initiator          string     `json:"Initiator"`
+	SecurityCredential string     `json:"SecurityCredential"`
+	CommandID          B2BCommand `json:"CommandID"`
+	// SenderIdentifierType and RecieverIdentifierType are "4" for shortcodes. RecieverIdentifierType is spelled
+	// the way Safaricom expects it.
+	SenderIdentifierType   string `json:"SenderIdentifierType"`
+	RecieverIdentifierType string `json:"RecieverIdentifierType"`
+	Amount                 string `json:"Amount"`
+	PartyA                 string `json:"PartyA"`
+	PartyB                 string `json:"PartyB"`
+	AccountReference       string `json:"AccountReference"` // The account number at the paybill being paid
+	Remarks                string `json:"Remarks"`
+	QueueTimeOutURL        string `json:"QueueTimeOutURL"`
+	ResultURL              string `json:"ResultURL"`
+}
+
+// validate checks the B2B request body before it is sent
+func (b *B2BRequestBody) validate(logger *slog.Logger) error {
+	err := validateCommand(
+		"CommandID", b.CommandID, B2BBusinessPayBill, B2BBusinessBuyGoods, B2BDisburseFundsToBusiness,
+		B2BBusinessToBusinessTransfer, B2BMerchantToMerchantTransfer,
+	)
+	if err != nil {
+		return err
+	}
+
+	return validateResultURLs(b.ResultURL, b.QueueTimeOutURL, logger)
+}
+
+// InitiateB2BRequest makes a http request paying another business from the shortcode in body.PartyA. The
+// initiator and security credential configured on the app are used when the body leaves them empty.
+func (m *Mpesa) InitiateB2BRequest(body *B2BRequestBody) (*AcknowledgementResponse, error) {
+	return m.InitiateB2BRequestWithContext(context.Background(), body)
+}
+
+// InitiateB2BRequestWithContext is InitiateB2BRequest with a context that cancels the request
+func (m *Mpesa) InitiateB2BRequestWithContext(ctx context.Context, body *B2BRequestBody) (*AcknowledgementResponse, error) {
+	b2bBody := *body
+
+	var err error
+	if b2bBody.Amount, err = b2bEndpoint.amount.normalize(b2bBody.Amount); err != nil {
+		return nil, err
+	}
+
+	if err := m.fillInitiator(&b2bBody.Initiator, &b2bBody.SecurityCredential); err != nil {
+		return nil, err
+	}
+
+	if err := b2bBody.validate(m.logger); err != nil {
+		return nil, err
+	}
+
+	ack := new(AcknowledgementResponse)
+	if _, err := m.send(ctx, b2bEndpoint, b2bBody, ack); err != nil {
+		return nil, err
+	}
+
+	return ack, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import "time"
+
+// b2cCompletedTimeLayout is the layout of the TransactionCompletedDateTime result parameter of B2C results
+const b2cCompletedTimeLayout = "02.01.2006 15:04:05"
+
+// B2CResultCallback is the result of a B2C payment posted to the ResultURL
+type B2CResultCallback struct {
+	Result ResultEnvelope `json:"Result"`
+}
+
+// B2CTimeoutCallback is posted to the QueueTimeOutURL when a B2C payment request timed out in Safaricom's queue
+// before it was processed. The payment was not made and the request can be sent again.
+type B2CTimeoutCallback struct {
+	Result ResultEnvelope `json:"Result"`
+}
+
+// TransactionReceipt returns the M-Pesa receipt number of the payment
+func (c *B2CResultCallback) TransactionReceipt() (string, bool) {
+	return c.Result.StringParameter("TransactionReceipt")
+}
+
+// TransactionAmount returns the amount paid to the customer
+func (c *B2CResultCallback) TransactionAmount() (float64, bool) {
+	return c.Result.NumberParameter("TransactionAmount")
+}
+
+// ReceiverPartyPublicName returns the phone number and name of the customer paid, e.g. "254708374149 - John Doe"
+func (c *B2CResultCallback) ReceiverPartyPublicName() (string, bool) {
+	return c.Result.StringParameter("ReceiverPartyPublicName")
+}
+
+// RecipientIsRegisteredCustomer reports whether the customer paid is registered on M-Pesa
+func (c *B2CResultCallback) RecipientIsRegisteredCustomer() (bool, bool) {
+	registered, ok := c.Result.StringParameter("B2CRecipientIsRegisteredCustomer")
+	return registered == "Y", ok
+}
+
+// UtilityAccountAvailableFunds returns the balance of the utility account the payment was made from
+func (c *B2CResultCallback) UtilityAccountAvailableFunds() (float64, bool) {
+	return c.Result.NumberParameter("B2CUtilityAccountAvailableFunds")
+}
+
+// WorkingAccountAvailableFunds returns the balance of the working account of the shortcode
+func (c *B2CResultCallback) WorkingAccountAvailableFunds() (float64, bool) {
+	return c.Result.NumberParameter("B2CWorkingAccountAvailableFunds")
+}
+
+// TransactionCompletedTime returns when the payment was completed, which Safaricom sends in Nairobi time
+func (c *B2CResultCallback) TransactionCompletedTime() (time.Time, bool) {
+	raw, ok := c.Result.StringParameter("TransactionCompletedDateTime")
+	if !ok {
+		return time.Time{}, false
+	}
+
+	completed, err := time.ParseInLocation(b2cCompletedTimeLayout, raw, mpesaTimeZone)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return completed, true
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+)
+
+// BillManagerOptInRequestBody is the body of a request onboarding a paybill on Bill Manager, which sends the
+// invoices of the paybill and the reminders to pay them
+type BillManagerOptInRequestBody struct {
+	ShortCode       string `json:"shortcode"`
+	Email           string `json:"email"`           // The address the invoices are sent from
+	OfficialContact string `json:"officialContact"` // The phone number customers reach the business on
+	SendReminders   string `json:"sendReminders"`   // "1" sends reminders before the due date, "0" does not
+	Logo            string `json:"logo,omitempty"`  // The image shown on the invoices, optional
+	CallbackURL     string `json:"callbackurl"`     // Receives the payments made against the invoices
+}
+
+// billManagerSuccessCode is the rescode of the Bill Manager requests that succeeded
+const billManagerSuccessCode = "200"
+
+// BillManagerResponse is the response sent back by the Bill Manager APIs
+type BillManagerResponse struct {
+	AppKey          string `json:"app_key"` // Only set by the opt-in
+	StatusMessage   string `json:"Status_Message"`
+	ResponseMessage string `json:"resmsg"`
+	ResponseCode    string `json:"rescode"` // "200" when the request succeeded
+}
+
+// Err returns the reason the request failed as an *MpesaError, or nil when it succeeded
+func (r *BillManagerResponse) Err() error {
+	if r.ResponseCode == billManagerSuccessCode {
+		return nil
+	}
+
+	return &MpesaError{ErrorCode: r.ResponseCode, ErrorMessage: r.ResponseMessage}
+}
+
+// validate checks the Bill Manager opt-in request body before it is sent
+func (b *BillManagerOptInRequestBody) validate() error {
+	if b.ShortCode == "" {
+		return &ValidationError{Field: "ShortCode", Reason: "must not be empty"}
+	}
+
+	if _, err := mail.ParseAddress(b.Email); err != nil {
+		return &ValidationError{Field: "Email", Reason: fmt.Sprintf("%q is not an email address", b.Email)}
+	}
+
+	if err := validateCommand("SendReminders", b.SendReminders, "0", "1"); err != nil {
+		return err
+	}
+
+	return validateHTTPSURL("CallbackURL", b.CallbackURL)
+}
+
+// BillManagerOptIn onboards the paybill in body.ShortCode on Bill Manager, which must be done once before it can
+// send invoices. Responses with a rescode other than "200" are returned as an *MpesaError.
+func (m *Mpesa) BillManagerOptIn(body *BillManagerOptInRequestBody) (*BillManagerResponse, error) {
+	return m.BillManagerOptInWithContext(context.Background(), body)
+}
+
+// BillManagerOptInWithContext is BillManagerOptIn with a context that cancels the request
+func (m *Mpesa) BillManagerOptInWithContext(ctx context.Context, body *BillManagerOptInRequestBody) (*BillManagerResponse, error) {
+	if err := body.validate(); err != nil {
+		return nil, err
+	}
+
+	billManagerResponse := new(BillManagerResponse)
+	if _, err := m.send(ctx, billManagerOptInEndpoint, body, billManagerResponse); err != nil {
+		return nil, err
+	}
+
+	if err := billManagerResponse.Err(); err != nil {
+		return nil, err
+	}
+
+	return billManagerResponse, nil
+}
+
+// InvoiceItem is a line of an invoice
+type InvoiceItem struct {
+	ItemName string `json:"itemName"`
+	Amount   string `json:"amount"` // Whole shillings, e.g. "10"
+}
+
+// SingleInvoiceRequestBody is the body of a request sending an invoice to a customer of a paybill onboarded on
+// Bill Manager
+type SingleInvoiceRequestBody struct {
+	ExternalReference string        `json:"externalReference"` // Identifies the invoice, unique for the paybill
+	BilledFullName    string        `json:"billedFullName"`
+	BilledPhoneNumber string        `json:"billedPhoneNumber"`
+	BilledPeriod      string        `json:"billedPeriod"` // e.g. "August 2021"
+	InvoiceName       string        `json:"invoiceName"`
+	DueDate           string        `json:"dueDate"`          // YYYY-MM-DD
+	AccountReference  string        `json:"accountReference"` // The account number the customer pays the invoice to
+	Amount            string        `json:"amount"`           // Whole shillings, e.g. "10"
+	InvoiceItems      []InvoiceItem `json:"invoiceItems,omitempty"`
+}
+
+// normalized checks the invoice and returns a copy with its amounts written the way the endpoint expects them
+func (b *SingleInvoiceRequestBody) normalized(e endpoint) (*SingleInvoiceRequestBody, error) {
+	invoice := *b
+	if invoice.ExternalReference == "" {
+		return nil, &ValidationError{Field: "ExternalReference", Reason: "must not be empty"}
+	}
+
+	if invoice.BilledPhoneNumber == "" {
+		return nil, &ValidationError{Field: "BilledPhoneNumber", Reason: "must not be empty"}
+	}
+
+	var err error
+	if invoice.Amount, err = e.amount.normalize(invoice.Amount); err != nil {
+		return nil, err
+	}
+
+	invoice.InvoiceItems = append([]InvoiceItem(nil), b.InvoiceItems...)
+	for i := range invoice.InvoiceItems {
+		if invoice.InvoiceItems[i].Amount, err = e.amount.normalize(invoice.InvoiceItems[i].Amount); err != nil {
+			return nil, err
+		}
+	}
+
+	return &invoice, nil
+}
+
+// SendSingleInvoice sends the invoice in body. Responses with a rescode other than "200" are returned as an
+// *MpesaError.
+func (m *Mpesa) SendSingleInvoice(body *SingleInvoiceRequestBody) (*BillManagerResponse, error) {
+	return m.SendSingleInvoiceWithContext(context.Background(), body)
+}
+
+// SendSingleInvoiceWithContext is SendSingleInvoice with a context that cancels the request
+func (m *Mpesa) SendSingleInvoiceWithContext(ctx context.Context, body *SingleInvoiceRequestBody) (*BillManagerResponse, error) {
+	invoice, err := body.normalized(singleInvoiceEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	billManagerResponse := new(BillManagerResponse)
+	if _, err := m.send(ctx, singleInvoiceEndpoint, invoice, billManagerResponse); err != nil {
+		return nil, err
+	}
+
+	if err := billManagerResponse.Err(); err != nil {
+		return nil, err
+	}
+
+	return billManagerResponse, nil
+}
+
+// InvoiceResult is the outcome of one of the invoices of SendBulkInvoices. Err is nil when the invoice was sent.
+type InvoiceResult struct {
+	ExternalReference string
+	Err               error
+}
+
+// SendBulkInvoices sends the invoices in a single request and returns the outcome of each of them, in order, so
+// that only the failed ones are sent again. The invoices that fail validation are left out of the request and
+// fail with their ValidationError. Safaricom accepts or rejects the request as a whole, so the other invoices
+// all fail with the error of the request, which is also returned.
+func (m *Mpesa) SendBulkInvoices(invoices []SingleInvoiceRequestBody) ([]InvoiceResult, error) {
+	return m.SendBulkInvoicesWithContext(context.Background(), invoices)
+}
+
+// SendBulkInvoicesWithContext is SendBulkInvoices with a context that cancels the request
+func (m *Mpesa) SendBulkInvoicesWithContext(ctx context.Context, invoices []SingleInvoiceRequestBody) ([]InvoiceResult, error) {
+	results := make([]InvoiceResult, len(invoices))
+	valid := make([]*SingleInvoiceRequestBody, 0, len(invoices))
+	sent := make([]int, 0, len(invoices))
+
+	for i := range invoices {
+		results[i].ExternalReference = invoices[i].ExternalReference
+
+		invoice, err := invoices[i].normalized(bulkInvoiceEndpoint)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		valid = append(valid, invoice)
+		sent = append(sent, i)
+	}
+
+	if len(valid) == 0 {
+		return results, nil
+	}
+
+	billManagerResponse := new(BillManagerResponse)
+	_, err := m.send(ctx, bulkInvoiceEndpoint, valid, billManagerResponse)
+	if err == nil {
+		err = billManagerResponse.Err()
+	}
+
+	for _, i := range sent {
+		results[i].Err = err
+	}
+
+	return results, err
+}
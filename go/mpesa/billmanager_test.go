@@ -0,0 +1,53 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestBillManagerOptIn(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantErrCode string
+	}{
+		{name: "opted in", body: `{"app_key":"AG_2376487236_126732989KJ","resmsg":"Success","rescode":"200"}`},
+		{name: "rejected", body: `{"resmsg":"Shortcode already onboarded","rescode":"409"}`, wantErrCode: "409"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMockServer()
+			defer s.Close()
+
+			s.Respond(billManagerOptInEndpoint.path, http.StatusOK, tt.body)
+			m := newTestMpesa(t, s, MpesaOpts{})
+
+			resp, err := m.BillManagerOptIn(&BillManagerOptInRequestBody{
+				ShortCode:       "718003",
+				Email:           "billing@example.com",
+				OfficialContact: "254708374149",
+				SendReminders:   "1",
+				CallbackURL:     "https://example.com/mpesa/billmanager",
+			})
+
+			if tt.wantErrCode != "" {
+				var mpesaErr *MpesaError
+				if !errors.As(err, &mpesaErr) || mpesaErr.ErrorCode != tt.wantErrCode {
+					t.Fatalf("BillManagerOptIn() error = %v, want an *MpesaError with code %s", err, tt.wantErrCode)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("BillManagerOptIn() error = %v", err)
+			}
+
+			if resp.AppKey == "" {
+				t.Error("BillManagerOptIn() returned no app_key")
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// C2BRegisterURLRequestBody is the body of a request registering the URLs Safaricom calls when a customer pays
+// into the shortcode from their phone
+type C2BRegisterURLRequestBody struct {
+	ShortCode string `json:"ShortCode"`
+	// ResponseType is what Safaricom does with the payment when the validation URL cannot be reached
+	ResponseType    C2BResponseType `json:"ResponseType"`
+	ConfirmationURL string          `json:"ConfirmationURL"`
+	ValidationURL   string          `json:"ValidationURL"`
+}
+
+// c2bURLForbiddenWords are the words Safaricom does not accept in the registered URLs
+var c2bURLForbiddenWords = []string{"mpesa", "m-pesa", "safaricom", "exe", "cmd", "sql", "query"}
+
+// validate checks the C2B URL registration before it is sent, since Safaricom accepts URLs it then never
+// delivers to
+func (b *C2BRegisterURLRequestBody) validate() error {
+	if err := validateCommand("ResponseType", b.ResponseType, C2BResponseCompleted, C2BResponseCancelled); err != nil {
+		return err
+	}
+
+	urls := []struct{ field, value string }{{"ConfirmationURL", b.ConfirmationURL}, {"ValidationURL", b.ValidationURL}}
+	for _, u := range urls {
+		if err := validateHTTPSURL(u.field, u.value); err != nil {
+			return err
+		}
+
+		lower := strings.ToLower(u.value)
+		for _, word := range c2bURLForbiddenWords {
+			if strings.Contains(lower, word) {
+				return &ValidationError{
+					Field:  u.field,
+					Reason: fmt.Sprintf("%q contains %q, which Safaricom does not accept in C2B URLs", u.value, word),
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// C2BRegisterURLResponse is the response sent back after registering the C2B URLs
+type C2BRegisterURLResponse struct {
+	// OriginatorCoversationID is spelled the way Safaricom sends it
+	OriginatorCoversationID string `json:"OriginatorCoversationID"`
+	ResponseCode            string `json:"ResponseCode"`
+	ResponseDescription     string `json:"ResponseDescription"`
+	RequestID               string `json:"requestId"`
+	ErrorCode               string `json:"errorCode"`
+	ErrorMessage            string `json:"errorMessage"`
+}
+
+// RegisterC2BURL registers the validation and confirmation URLs of the shortcode, which must be done before
+// customers can pay into it from their phone
+func (m *Mpesa) RegisterC2BURL(body *C2BRegisterURLRequestBody) (*C2BRegisterURLResponse, error) {
+	return m.RegisterC2BURLWithContext(context.Background(), body)
+}
+
+// RegisterC2BURLWithContext is RegisterC2BURL with a context that cancels the request
+func (m *Mpesa) RegisterC2BURLWithContext(ctx context.Context, body *C2BRegisterURLRequestBody) (*C2BRegisterURLResponse, error) {
+	if err := body.validate(); err != nil {
+		return nil, err
+	}
+
+	registerResponse := new(C2BRegisterURLResponse)
+	if _, err := m.send(ctx, c2bRegisterURLEndpoint, body, registerResponse); err != nil {
+		return nil, err
+	}
+
+	return registerResponse, nil
+}
+
+// C2BSimulateRequestBody is the body of a request simulating a payment from a customer's phone into the shortcode
+type C2BSimulateRequestBody struct {
+	ShortCode     string     `json:"ShortCode"`
+	CommandID     C2BCommand `json:"CommandID"`
+	Amount        string     `json:"Amount"`
+	Msisdn        string     `json:"Msisdn"`
+	BillRefNumber string     `json:"BillRefNumber"` // The account number for paybills, empty for buy goods
+}
+
+// C2BSimulateResponse is the response sent back after simulating a C2B payment
+type C2BSimulateResponse struct {
+	ConversationID          string `json:"ConversationID"`
+	OriginatorCoversationID string `json:"OriginatorCoversationID"`
+	ResponseDescription     string `json:"ResponseDescription"`
+	RequestID               string `json:"requestId"`
+	ErrorCode               string `json:"errorCode"`
+	ErrorMessage            string `json:"errorMessage"`
+}
+
+// SimulateC2BTransaction simulates a customer paying into the shortcode from their phone, which calls the
+// registered validation and confirmation URLs. Safaricom only offers this API in the sandbox, on production the
+// payments have to be made from a real phone.
+func (m *Mpesa) SimulateC2BTransaction(body *C2BSimulateRequestBody) (*C2BSimulateResponse, error) {
+	return m.SimulateC2BTransactionWithContext(context.Background(), body)
+}
+
+// SimulateC2BTransactionWithContext is SimulateC2BTransaction with a context that cancels the request
+func (m *Mpesa) SimulateC2BTransactionWithContext(ctx context.Context, body *C2BSimulateRequestBody) (*C2BSimulateResponse, error) {
+	if err := ValidateBillRefNumber(body.CommandID, body.BillRefNumber, m.billRefNumberPattern); err != nil {
+		return nil, err
+	}
+
+	simulateBody := *body
+
+	var err error
+	if simulateBody.Amount, err = c2bSimulateEndpoint.amount.normalize(simulateBody.Amount); err != nil {
+		return nil, err
+	}
+
+	simulateResponse := new(C2BSimulateResponse)
+	if _, err := m.send(ctx, c2bSimulateEndpoint, simulateBody, simulateResponse); err != nil {
+		return nil, err
+	}
+
+	return simulateResponse, nil
+}
+
+// C2BCallback is the payment Safaricom posts to the validation URL before completing it, and to the
+// confirmation URL once it is completed
+type C2BCallback struct {
+	TransactionType   string `json:"TransactionType"` // "Pay Bill" or "Buy Goods"
+	TransID           string `json:"TransID"`
+	TransTime         string `json:"TransTime"`   // YYYYMMDDHHmmss in Nairobi time, see Time
+	TransAmount       string `json:"TransAmount"` // e.g. "10" or "10.00"
+	BusinessShortCode string `json:"BusinessShortCode"`
+	BillRefNumber     string `json:"BillRefNumber"` // The account number entered by the customer for paybills
+	InvoiceNumber     string `json:"InvoiceNumber"`
+	OrgAccountBalance string `json:"OrgAccountBalance"` // Empty in validation requests
+	ThirdPartyTransID string `json:"ThirdPartyTransID"`
+	MSISDN            string `json:"MSISDN"` // Masked by Safaricom, e.g. "2547*****149"
+	FirstName         string `json:"FirstName"`
+	MiddleName        string `json:"MiddleName"`
+	LastName          string `json:"LastName"`
+}
+
+// Time returns when the payment was made
+func (c *C2BCallback) Time() (time.Time, error) {
+	return ParseMpesaTimestamp(c.TransTime)
+}
+
+// command returns the C2BCommand of the payment from its TransactionType, or "" when it is neither a paybill nor
+// a buy goods payment
+func (c *C2BCallback) command() C2BCommand {
+	switch c.TransactionType {
+	case "Pay Bill", string(C2BCustomerPayBillOnline):
+		return C2BCustomerPayBillOnline
+	case "Buy Goods", string(C2BCustomerBuyGoodsOnline):
+		return C2BCustomerBuyGoodsOnline
+	}
+
+	return ""
+}
+
+// C2BRejectionCode is the ResultCode a validation URL answers with to reject a C2B payment
+type C2BRejectionCode string
+
+const (
+	C2BRejectInvalidMSISDN        C2BRejectionCode = "C2B00011"
+	C2BRejectInvalidAccountNumber C2BRejectionCode = "C2B00012"
+	C2BRejectInvalidAmount        C2BRejectionCode = "C2B00013"
+	C2BRejectInvalidKYCDetails    C2BRejectionCode = "C2B00014"
+	C2BRejectInvalidShortcode     C2BRejectionCode = "C2B00015"
+	C2BRejectOtherError           C2BRejectionCode = "C2B00016"
+)
+
+// C2BValidationResponse is the body the validation URL answers with to accept or reject the payment
+type C2BValidationResponse struct {
+	ResultCode string `json:"ResultCode"`
+	ResultDesc string `json:"ResultDesc"`
+}
+
+// AcceptC2BPayment returns the validation response letting Safaricom complete the payment
+func AcceptC2BPayment() *C2BValidationResponse {
+	return &C2BValidationResponse{ResultCode: "0", ResultDesc: "Accepted"}
+}
+
+// RejectC2BPayment returns the validation response making Safaricom cancel the payment for the given reason
+func RejectC2BPayment(code C2BRejectionCode) *C2BValidationResponse {
+	return &C2BValidationResponse{ResultCode: string(code), ResultDesc: "Rejected"}
+}
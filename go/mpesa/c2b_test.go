@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+)
+
+// accountNumberPattern is the account number format of the tests, like the "A123" of the testdata callbacks
+var accountNumberPattern = regexp.MustCompile(`^A\d+$`)
+
+func TestSimulateC2BTransactionBillRefNumberPattern(t *testing.T) {
+	tests := []struct {
+		name          string
+		billRefNumber string
+		wantField     string
+		wantRequests  int
+	}{
+		{name: "matching account number", billRefNumber: "A123", wantRequests: 1},
+		{name: "other format", billRefNumber: "B123", wantField: "BillRefNumber"},
+		{name: "no account number", wantField: "BillRefNumber"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMockServer()
+			defer s.Close()
+
+			s.Respond(c2bSimulateEndpoint.path, http.StatusOK, `{
+				"ConversationID": "AG_20191219_00005797af5d7d75f652",
+				"OriginatorCoversationID": "16740-34861180-1",
+				"ResponseDescription": "Accept the service request successfully."
+			}`)
+			m := newTestMpesa(t, s, MpesaOpts{BillRefNumberPattern: accountNumberPattern})
+
+			_, err := m.SimulateC2BTransaction(&C2BSimulateRequestBody{
+				ShortCode:     "600000",
+				CommandID:     C2BCustomerPayBillOnline,
+				Amount:        "10",
+				Msisdn:        "254708374149",
+				BillRefNumber: tt.billRefNumber,
+			})
+
+			assertValidationField(t, err, tt.wantField)
+
+			if got := s.Requests(c2bSimulateEndpoint.path); got != tt.wantRequests {
+				t.Errorf("simulate requests = %d, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestC2BValidationBillRefNumber(t *testing.T) {
+	m := &Mpesa{billRefNumberPattern: accountNumberPattern}
+
+	tests := []struct {
+		name            string
+		transactionType string
+		billRefNumber   string
+		wantResultCode  string
+		wantCalled      bool
+	}{
+		{name: "paybill with a matching account", transactionType: "Pay Bill", billRefNumber: "A123", wantResultCode: "0", wantCalled: true},
+		{name: "paybill with another format", transactionType: "Pay Bill", billRefNumber: "B123", wantResultCode: string(C2BRejectInvalidAccountNumber)},
+		{name: "paybill without account", transactionType: "Pay Bill", wantResultCode: string(C2BRejectInvalidAccountNumber)},
+		{name: "buy goods without account", transactionType: "Buy Goods", wantResultCode: "0", wantCalled: true},
+		{name: "buy goods with an account", transactionType: "Buy Goods", billRefNumber: "A123", wantResultCode: string(C2BRejectInvalidAccountNumber)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := m.NewCallbackRouter()
+
+			called := false
+			r.OnC2BValidation("/c2b/validation", func(*C2BCallback) *C2BValidationResponse {
+				called = true
+				return nil
+			})
+
+			body, _ := json.Marshal(&C2BCallback{TransactionType: tt.transactionType, BillRefNumber: tt.billRefNumber})
+			rec := postCallback(r, "/c2b/validation", string(body))
+
+			// Accepted payments are acknowledged with a numeric ResultCode, rejected ones with a string
+			var reply map[string]interface{}
+			if err := json.NewDecoder(rec.Body).Decode(&reply); err != nil {
+				t.Fatalf("decoding the reply: %v", err)
+			}
+
+			if got := fmt.Sprint(reply["ResultCode"]); got != tt.wantResultCode {
+				t.Errorf("ResultCode = %q, want %q", got, tt.wantResultCode)
+			}
+
+			if called != tt.wantCalled {
+				t.Errorf("fn called = %v, want %v", called, tt.wantCalled)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// decodeCallbacks decodes a callback body holding either a single callback or, when a gateway in front of the
+// endpoint batches deliveries, a JSON array of callbacks. Arrays holding a null are rejected.
+func decodeCallbacks[T any](r io.Reader) ([]*T, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if trimmed := bytes.TrimLeft(body, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '[' {
+		var payloads []*T
+		if err := json.Unmarshal(trimmed, &payloads); err != nil {
+			return nil, err
+		}
+
+		for i, payload := range payloads {
+			if payload == nil {
+				return nil, fmt.Errorf("mpesa: callback %d of the batch is null", i)
+			}
+		}
+
+		return payloads, nil
+	}
+
+	payload := new(T)
+	if err := json.Unmarshal(body, payload); err != nil {
+		return nil, err
+	}
+
+	return []*T{payload}, nil
+}
+
+// DecodeCallback decodes the callback of type T read from r, for handlers that do not use a CallbackRouter. When
+// disallowUnknownFields is set, fields that T does not declare are reported as errors. Errors say what was being
+// decoded.
+func DecodeCallback[T any](r io.Reader, disallowUnknownFields bool) (*T, error) {
+	payload := new(T)
+
+	decoder := json.NewDecoder(r)
+	if disallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+
+	if err := decoder.Decode(payload); err != nil {
+		return nil, fmt.Errorf("mpesa: decoding %s: %w", reflect.TypeOf(payload).Elem().Name(), err)
+	}
+
+	return payload, nil
+}
+
+// DecodeSTKCallback decodes the STK push callback read from r
+func DecodeSTKCallback(r io.Reader) (*STKPushCallbackResponse, error) {
+	return DecodeCallback[STKPushCallbackResponse](r, false)
+}
+
+// DecodeC2BCallback decodes the C2B validation or confirmation read from r
+func DecodeC2BCallback(r io.Reader) (*C2BCallback, error) {
+	return DecodeCallback[C2BCallback](r, false)
+}
+
+// DecodeB2CResult decodes the B2C result read from r
+func DecodeB2CResult(r io.Reader) (*B2CResultCallback, error) {
+	return DecodeCallback[B2CResultCallback](r, false)
+}
+
+// DecodeB2CTimeout decodes the B2C timeout read from r
+func DecodeB2CTimeout(r io.Reader) (*B2CTimeoutCallback, error) {
+	return DecodeCallback[B2CTimeoutCallback](r, false)
+}
+
+// DecodeAccountBalanceResult decodes the account balance result read from r
+func DecodeAccountBalanceResult(r io.Reader) (*AccountBalanceResultCallback, error) {
+	return DecodeCallback[AccountBalanceResultCallback](r, false)
+}
+
+// DecodeTransactionStatusResult decodes the transaction status result read from r
+func DecodeTransactionStatusResult(r io.Reader) (*TransactionStatusResultCallback, error) {
+	return DecodeCallback[TransactionStatusResultCallback](r, false)
+}
+
+// DecodeReversalResult decodes the reversal result read from r
+func DecodeReversalResult(r io.Reader) (*ReversalResultCallback, error) {
+	return DecodeCallback[ReversalResultCallback](r, false)
+}
+
+// DecodeTaxRemittanceResult decodes the tax remittance result read from r
+func DecodeTaxRemittanceResult(r io.Reader) (*TaxRemittanceResultCallback, error) {
+	return DecodeCallback[TaxRemittanceResultCallback](r, false)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+)
+
+// safaricomCallbackIPs are the addresses Safaricom documents its callbacks and results being posted from
+var safaricomCallbackIPs = []netip.Addr{
+	netip.MustParseAddr("196.201.214.200"),
+	netip.MustParseAddr("196.201.214.206"),
+	netip.MustParseAddr("196.201.213.114"),
+	netip.MustParseAddr("196.201.214.207"),
+	netip.MustParseAddr("196.201.214.208"),
+	netip.MustParseAddr("196.201.213.44"),
+	netip.MustParseAddr("196.201.212.127"),
+	netip.MustParseAddr("196.201.212.138"),
+	netip.MustParseAddr("196.201.212.129"),
+	netip.MustParseAddr("196.201.212.136"),
+	netip.MustParseAddr("196.201.212.74"),
+	netip.MustParseAddr("196.201.212.69"),
+}
+
+// IsSafaricomIP reports whether ip, with or without a port, is one of the addresses Safaricom posts callbacks from
+func IsSafaricomIP(ip string) bool {
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+	for _, safaricomIP := range safaricomCallbackIPs {
+		if addr == safaricomIP {
+			return true
+		}
+	}
+
+	return false
+}
+
+// VerifyCallbackSource rejects the requests that do not come from a Safaricom address with 403 before they reach
+// next. The remote address of the connection is checked, so the server must be reached directly and not through
+// a proxy.
+func VerifyCallbackSource(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !IsSafaricomIP(r.RemoteAddr) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
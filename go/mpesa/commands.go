@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// STKTransactionType is the TransactionType of an M-Pesa Express (STK Push) request
+type STKTransactionType string
+
+const (
+	// STKCustomerPayBillOnline charges the customer into a paybill number
+	STKCustomerPayBillOnline STKTransactionType = "CustomerPayBillOnline"
+	// STKCustomerBuyGoodsOnline charges the customer into a till number
+	STKCustomerBuyGoodsOnline STKTransactionType = "CustomerBuyGoodsOnline"
+)
+
+// C2BCommand is the CommandID of a C2B transaction
+type C2BCommand string
+
+const (
+	C2BCustomerPayBillOnline  C2BCommand = "CustomerPayBillOnline"
+	C2BCustomerBuyGoodsOnline C2BCommand = "CustomerBuyGoodsOnline"
+)
+
+// C2BResponseType is what Safaricom does with a C2B payment when the validation URL cannot be reached
+type C2BResponseType string
+
+const (
+	C2BResponseCompleted C2BResponseType = "Completed"
+	C2BResponseCancelled C2BResponseType = "Cancelled"
+)
+
+// B2CCommand is the CommandID of a B2C payment request
+type B2CCommand string
+
+const (
+	B2CBusinessPayment  B2CCommand = "BusinessPayment"
+	B2CSalaryPayment    B2CCommand = "SalaryPayment"
+	B2CPromotionPayment B2CCommand = "PromotionPayment"
+)
+
+// B2BCommand is the CommandID of a B2B payment request
+type B2BCommand string
+
+const (
+	B2BBusinessPayBill            B2BCommand = "BusinessPayBill"
+	B2BBusinessBuyGoods           B2BCommand = "BusinessBuyGoods"
+	B2BDisburseFundsToBusiness    B2BCommand = "DisburseFundsToBusiness"
+	B2BBusinessToBusinessTransfer B2BCommand = "BusinessToBusinessTransfer"
+	B2BMerchantToMerchantTransfer B2BCommand = "MerchantToMerchantTransfer"
+)
+
+// ReversalCommand is the CommandID of a transaction reversal request
+type ReversalCommand string
+
+const ReversalTransactionReversal ReversalCommand = "TransactionReversal"
+
+// TransactionStatusCommand is the CommandID of a transaction status query
+type TransactionStatusCommand string
+
+const TransactionStatusQuery TransactionStatusCommand = "TransactionStatusQuery"
+
+// AccountBalanceCommand is the CommandID of an account balance query
+type AccountBalanceCommand string
+
+const AccountBalanceQuery AccountBalanceCommand = "AccountBalance"
+
+// TaxRemittanceCommand is the CommandID of a tax remittance request
+type TaxRemittanceCommand string
+
+const TaxRemittancePayTaxToKRA TaxRemittanceCommand = "PayTaxToKRA"
+
+// RatibaTransactionType is the TransactionType of a standing order
+type RatibaTransactionType string
+
+const (
+	RatibaCustomerPayBill     RatibaTransactionType = "Standing Order Customer Pay Bill"
+	RatibaCustomerPayMerchant RatibaTransactionType = "Standing Order Customer Pay Marchant" // Spelled the way Safaricom expects it
+)
+
+// validateCommand checks that command is one of the allowed values for the field
+func validateCommand[T ~string](field string, command T, allowed ...T) error {
+	for _, c := range allowed {
+		if command == c {
+			return nil
+		}
+	}
+
+	values := make([]string, len(allowed))
+	for i, c := range allowed {
+		values[i] = string(c)
+	}
+
+	return &ValidationError{
+		Field:  field,
+		Reason: fmt.Sprintf("%q is not one of %s", command, strings.Join(values, ", ")),
+	}
+}
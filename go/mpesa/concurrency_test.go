@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentUse shares one app between goroutines generating tokens, initiating deduplicated STK pushes and
+// correlating their callbacks, run it with -race
+func TestConcurrentUse(t *testing.T) {
+	s := NewMockServer()
+	defer s.Close()
+
+	m := newTestMpesa(t, s, MpesaOpts{})
+
+	var callback STKPushCallbackResponse
+	if err := json.Unmarshal([]byte(readCallback(t, "stk_success.json")), &callback); err != nil {
+		t.Fatal(err)
+	}
+
+	const goroutines, keys = 40, 5
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 3*goroutines)
+
+	for i := 0; i < goroutines; i++ {
+		wg.Add(3)
+
+		go func() {
+			defer wg.Done()
+
+			if _, err := m.AccessToken(context.Background()); err != nil {
+				errs <- err
+			}
+		}()
+
+		go func(key string) {
+			defer wg.Done()
+
+			if _, err := m.InitiateSTKPushRequestIdempotent(key, testSTKPushBody()); err != nil {
+				errs <- err
+			}
+		}(fmt.Sprintf("order-%d", i%keys))
+
+		go func() {
+			defer wg.Done()
+
+			if _, _, err := m.Correlate(context.Background(), &callback); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if got := s.Requests(oauthEndpoint.path); got != 1 {
+		t.Errorf("oauth requests = %d, want 1", got)
+	}
+
+	if got := s.Requests(stkPushEndpoint.path); got != keys {
+		t.Errorf("STK push requests = %d, want one per idempotency key, %d", got, keys)
+	}
+
+	if _, ok, err := m.Correlate(context.Background(), &callback); !ok || err != nil {
+		t.Errorf("Correlate() = %v, %v, want the correlation of the STK pushes", ok, err)
+	}
+}
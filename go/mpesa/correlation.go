@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultCorrelationTTL is how long correlations are kept when MpesaOpts.CorrelationTTL is not set
+const defaultCorrelationTTL = 24 * time.Hour
+
+// Correlation holds the details of an STK push initiation, used to match the callback to the request that
+// caused it.
+type Correlation struct {
+	CheckoutRequestID string
+	MerchantRequestID string
+	BusinessShortCode string
+	PhoneNumber       string
+	Amount            string
+	AccountReference  string
+	InitiatedAt       time.Time
+}
+
+// CorrelationStore keeps the correlations of the initiated STK pushes by CheckoutRequestID. The default store
+// keeps them in memory, a store backed by a database lets callbacks arriving after a restart be matched.
+type CorrelationStore interface {
+	// Put stores the correlation for ttl
+	Put(ctx context.Context, checkoutRequestID string, c *Correlation, ttl time.Duration) error
+	// Get returns the correlation stored for the checkoutRequestID, ok is false when there is none
+	Get(ctx context.Context, checkoutRequestID string) (c *Correlation, ok bool, err error)
+}
+
+type memoryCorrelation struct {
+	correlation *Correlation
+	expiresAt   time.Time
+}
+
+// memoryCorrelationSweepInterval is how many correlations MemoryCorrelationStore stores between two sweeps of the
+// expired ones
+const memoryCorrelationSweepInterval = 1000
+
+// MemoryCorrelationStore is a CorrelationStore keeping the correlations in memory
+type MemoryCorrelationStore struct {
+	mu           sync.Mutex
+	correlations map[string]memoryCorrelation
+	puts         int
+}
+
+// NewMemoryCorrelationStore returns an empty MemoryCorrelationStore
+func NewMemoryCorrelationStore() *MemoryCorrelationStore {
+	return &MemoryCorrelationStore{correlations: make(map[string]memoryCorrelation)}
+}
+
+// Put stores the correlation for ttl. The correlations that expired are dropped by Get, and by a sweep every
+// 1000 puts for those that are never looked up.
+func (s *MemoryCorrelationStore) Put(_ context.Context, checkoutRequestID string, c *Correlation, ttl time.Duration) error {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.puts++; s.puts%memoryCorrelationSweepInterval == 0 {
+		for id, stored := range s.correlations {
+			if now.After(stored.expiresAt) {
+				delete(s.correlations, id)
+			}
+		}
+	}
+
+	s.correlations[checkoutRequestID] = memoryCorrelation{correlation: c, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+// Get returns the correlation stored for the checkoutRequestID if it has not expired, dropping it otherwise
+func (s *MemoryCorrelationStore) Get(_ context.Context, checkoutRequestID string) (*Correlation, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.correlations[checkoutRequestID]
+	if !ok {
+		return nil, false, nil
+	}
+
+	if time.Now().After(stored.expiresAt) {
+		delete(s.correlations, checkoutRequestID)
+		return nil, false, nil
+	}
+
+	return stored.correlation, true, nil
+}
+
+// recordCorrelation stores the correlation of an accepted STK push. The push has already been sent, so a
+// failure to store it is logged rather than returned.
+func (m *Mpesa) recordCorrelation(ctx context.Context, body *STKPushRequestBody, resp *STKPushRequestResponse) {
+	correlation := &Correlation{
+		CheckoutRequestID: resp.CheckoutRequestID,
+		MerchantRequestID: resp.MerchantRequestID,
+		BusinessShortCode: body.BusinessShortCode,
+		PhoneNumber:       body.PhoneNumber,
+		Amount:            body.Amount,
+		AccountReference:  body.AccountReference,
+		InitiatedAt:       time.Now(),
+	}
+
+	if err := m.correlations.Put(ctx, resp.CheckoutRequestID, correlation, m.correlationTTL); err != nil {
+		m.logger.ErrorContext(
+			ctx, "mpesa: storing the STK push correlation failed",
+			"checkout_request_id", resp.CheckoutRequestID, "error", err,
+		)
+	}
+}
+
+// Correlate returns the details of the STK push initiation the callback belongs to
+func (m *Mpesa) Correlate(ctx context.Context, callback *STKPushCallbackResponse) (*Correlation, bool, error) {
+	return m.correlations.Get(ctx, callback.Body.StkCallback.CheckoutRequestID)
+}
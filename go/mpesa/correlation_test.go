@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryCorrelationStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryCorrelationStore()
+
+	if err := s.Put(ctx, "ws_CO_expired", &Correlation{}, -time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put(ctx, "ws_CO_valid", &Correlation{}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, _ := s.Get(ctx, "ws_CO_expired"); ok {
+		t.Error("Get() returned an expired correlation")
+	}
+
+	if _, ok := s.correlations["ws_CO_expired"]; ok {
+		t.Error("Get() kept the expired correlation")
+	}
+
+	if _, ok, _ := s.Get(ctx, "ws_CO_valid"); !ok {
+		t.Error("Get() did not return the valid correlation")
+	}
+}
+
+func TestMemoryCorrelationStoreSweep(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryCorrelationStore()
+
+	for i := 0; i < memoryCorrelationSweepInterval-1; i++ {
+		if err := s.Put(ctx, fmt.Sprintf("ws_CO_%d", i), &Correlation{}, -time.Second); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := s.Put(ctx, "ws_CO_valid", &Correlation{}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(s.correlations); got != 1 {
+		t.Errorf("%d correlations are kept after the sweep, want 1", got)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ErrInvalidCredentials is returned when Safaricom rejects the consumer key and secret, usually because they
+// were revoked or rotated.
+var ErrInvalidCredentials = errors.New("mpesa: the consumer key and secret were rejected")
+
+// invalidAuthenticationErrorCode is the error code of a token request with a wrong consumer key or secret
+const invalidAuthenticationErrorCode = "400.008.01"
+
+// credentialsRejected reports whether the token response means the consumer key and secret are not valid.
+// Safaricom answers either with the invalid authentication error code or with an empty 400 response.
+func credentialsRejected(statusCode int, resp *MpesaAccessTokenResponse) bool {
+	if resp.ErrorCode == invalidAuthenticationErrorCode || statusCode == http.StatusUnauthorized {
+		return true
+	}
+
+	return statusCode == http.StatusBadRequest && resp.ErrorCode == "" && resp.AccessToken == ""
+}
+
+// rejectCredentials records that the credentials were rejected, starting the backoff when one is configured,
+// and returns the error for the caller.
+func (m *Mpesa) rejectCredentials(resp *MpesaAccessTokenResponse) error {
+	m.logger.Error(
+		"mpesa: the consumer key and secret were rejected, they need to be rotated",
+		"error_code", resp.ErrorCode, "error_message", resp.ErrorMessage, "backoff", m.credentialsBackoff,
+	)
+
+	if m.credentialsBackoff > 0 {
+		m.mu.Lock()
+		m.credentialsRejectedUntil = time.Now().Add(m.credentialsBackoff)
+		m.mu.Unlock()
+	}
+
+	if resp.ErrorMessage != "" {
+		return fmt.Errorf("%w: %s", ErrInvalidCredentials, resp.ErrorMessage)
+	}
+
+	return ErrInvalidCredentials
+}
+
+// checkCredentialsBackoff fails with ErrInvalidCredentials while the backoff after a rejection is running
+func (m *Mpesa) checkCredentialsBackoff() error {
+	m.mu.RLock()
+	until := m.credentialsRejectedUntil
+	m.mu.RUnlock()
+
+	if time.Now().Before(until) {
+		return fmt.Errorf("%w: not retrying until %s", ErrInvalidCredentials, until.Format(time.RFC3339))
+	}
+
+	return nil
+}
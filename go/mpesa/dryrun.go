@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// dryRunAccessToken is the access token of the dry run requests when the TokenStore holds none
+const dryRunAccessToken = "dry-run-access-token"
+
+// DryRunError is returned by every request of an app created with MpesaOpts.DryRun, in place of the response.
+// It holds the request exactly as it would have been sent, with its headers and JSON body.
+type DryRunError struct {
+	Endpoint string // A short label such as "stkpush"
+	Request  *http.Request
+	Body     []byte
+}
+
+func (e *DryRunError) Error() string {
+	return fmt.Sprintf("mpesa: dry run, the %s request to %s was not sent", e.Endpoint, e.Request.URL)
+}
+
+// dryRunToken returns the token of the TokenStore, or a placeholder when it holds none, so that dry runs never
+// call Safaricom to generate one
+func (m *Mpesa) dryRunToken(ctx context.Context) *AccessToken {
+	if token, ok := m.storedAccessToken(ctx); ok {
+		return token
+	}
+
+	return &AccessToken{Token: dryRunAccessToken}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDryRunNeverCallsSafaricom(t *testing.T) {
+	s := NewMockServer()
+	defer s.Close()
+
+	m := newTestMpesa(t, s, MpesaOpts{DryRun: true})
+
+	calls := []struct {
+		name         string
+		call         func() error
+		wantEndpoint string
+	}{
+		{name: "AccessToken", call: func() error {
+			_, err := m.AccessToken(context.Background())
+			return err
+		}, wantEndpoint: oauthEndpoint.name},
+		{name: "Ping", call: func() error { return m.Ping(context.Background()) }, wantEndpoint: oauthEndpoint.name},
+		{name: "InitiateSTKPushRequest", call: func() error {
+			_, err := m.InitiateSTKPushRequest(testSTKPushBody())
+			return err
+		}, wantEndpoint: stkPushEndpoint.name},
+	}
+
+	for _, tt := range calls {
+		t.Run(tt.name, func(t *testing.T) {
+			var dryRunErr *DryRunError
+			if err := tt.call(); !errors.As(err, &dryRunErr) || dryRunErr.Endpoint != tt.wantEndpoint {
+				t.Fatalf("%s() error = %v, want the *DryRunError of %s", tt.name, err, tt.wantEndpoint)
+			}
+		})
+	}
+
+	for _, path := range []string{oauthEndpoint.path, stkPushEndpoint.path} {
+		if got := s.Requests(path); got != 0 {
+			t.Errorf("requests to %s = %d, want 0", path, got)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Environment is the Safaricom environment the app runs against
+type Environment string
+
+const (
+	Sandbox    Environment = "sandbox"
+	Production Environment = "production"
+)
+
+// environmentBaseURLs are the base URLs of the Safaricom environments
+var environmentBaseURLs = map[Environment]string{
+	Sandbox:    "https://sandbox.safaricom.co.ke",
+	Production: "https://api.safaricom.co.ke",
+}
+
+// baseURL returns the base URL of the environment. The empty environment is the sandbox.
+func (e Environment) baseURL() (string, error) {
+	if e == "" {
+		e = Sandbox
+	}
+
+	baseURL, ok := environmentBaseURLs[e]
+	if !ok {
+		return "", fmt.Errorf("mpesa: unknown Environment %q, use Sandbox or Production", e)
+	}
+
+	return baseURL, nil
+}
+
+// endpoint describes one of the Safaricom APIs called by the app.
+//
+// An endpoint is idempotent when sending the same request twice cannot move money twice. Token generation and
+// the query APIs are idempotent and are retried automatically on transient failures. Requests that move money,
+// like STK push and B2C, are only retried when MpesaOpts.RetryNonIdempotent is set, since a request that timed
+// out may still have gone through and retrying it charges or pays the customer a second time.
+//
+// The amount format is how the endpoint expects the Amount field to be written, so the rules for each product
+// are declared here instead of at every call site.
+type endpoint struct {
+	name       string // A short label identifying the endpoint in logs
+	path       string
+	idempotent bool
+	amount     amountFormat
+}
+
+var (
+	oauthEndpoint             = endpoint{name: "oauth", path: "/oauth/v1/generate", idempotent: true}
+	stkPushEndpoint           = endpoint{name: "stkpush", path: "/mpesa/stkpush/v1/processrequest", amount: wholeShillings}
+	b2cEndpoint               = endpoint{name: "b2c", path: "/mpesa/b2c/v1/paymentrequest", amount: wholeShillings}
+	stkQueryEndpoint          = endpoint{name: "stkpushquery", path: "/mpesa/stkpushquery/v1/query", idempotent: true}
+	c2bRegisterURLEndpoint    = endpoint{name: "c2bregisterurl", path: "/mpesa/c2b/v1/registerurl", idempotent: true}
+	c2bSimulateEndpoint       = endpoint{name: "c2bsimulate", path: "/mpesa/c2b/v1/simulate", amount: wholeShillings}
+	accountBalanceEndpoint    = endpoint{name: "accountbalance", path: "/mpesa/accountbalance/v1/query", idempotent: true}
+	transactionStatusEndpoint = endpoint{name: "transactionstatus", path: "/mpesa/transactionstatus/v1/query", idempotent: true}
+	reversalEndpoint          = endpoint{name: "reversal", path: "/mpesa/reversal/v1/request", amount: wholeShillings}
+	b2bEndpoint               = endpoint{name: "b2b", path: "/mpesa/b2b/v1/paymentrequest", amount: decimalShillings}
+	dynamicQREndpoint         = endpoint{name: "qrcode", path: "/mpesa/qrcode/v1/generate", idempotent: true, amount: wholeShillings}
+	ratibaEndpoint            = endpoint{name: "ratiba", path: "/standingorder/v1/createStandingOrderExternal", amount: wholeShillings}
+	taxRemittanceEndpoint     = endpoint{name: "remittax", path: "/mpesa/b2b/v1/remittax", amount: wholeShillings}
+	billManagerOptInEndpoint  = endpoint{name: "billmanageroptin", path: "/v1/billmanager-invoice/optin", idempotent: true}
+	singleInvoiceEndpoint     = endpoint{name: "singleinvoice", path: "/v1/billmanager-invoice/single-invoicing", amount: wholeShillings}
+	bulkInvoiceEndpoint       = endpoint{name: "bulkinvoice", path: "/v1/billmanager-invoice/bulk-invoicing", amount: wholeShillings}
+)
+
+// endpointURL returns the absolute URL of the endpoint for the environment the app runs against
+func (m *Mpesa) endpointURL(e endpoint) (string, error) {
+	return url.JoinPath(m.baseURL, e.path)
+}
+
+// oauthGrantType is the grant type of the access token requests, the only one Safaricom supports
+const oauthGrantType = "client_credentials"
+
+// tokenURL returns the URL of the access token requests, with the query parameters they are made with
+func (m *Mpesa) tokenURL() (string, error) {
+	endpointURL, err := m.endpointURL(oauthEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{"grant_type": {oauthGrantType}}
+	return endpointURL + "?" + query.Encode(), nil
+}
+
+// canonicalBaseURL validates the base URL and strips its trailing slashes, so that the endpoint URLs joined to
+// it never contain a double slash.
+func canonicalBaseURL(baseURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(baseURL))
+	if err != nil {
+		return "", fmt.Errorf("mpesa: invalid BaseURL %q: %w", baseURL, err)
+	}
+
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return "", fmt.Errorf("mpesa: invalid BaseURL %q: an absolute http or https URL is required", baseURL)
+	}
+
+	if u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("mpesa: invalid BaseURL %q: query and fragment are not allowed", baseURL)
+	}
+
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = ""
+
+	return u.String(), nil
+}
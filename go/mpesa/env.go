@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// The environment variables read by NewMpesaFromEnv
+const (
+	envConsumerKey    = "MPESA_CONSUMER_KEY"
+	envConsumerSecret = "MPESA_CONSUMER_SECRET"
+	envEnvironment    = "MPESA_ENVIRONMENT"
+	envShortcode      = "MPESA_SHORTCODE"
+	envPasskey        = "MPESA_PASSKEY"
+	envCallbackURL    = "MPESA_CALLBACK_URL"
+)
+
+// NewMpesaFromEnv returns an app configured from the MPESA_CONSUMER_KEY, MPESA_CONSUMER_SECRET,
+// MPESA_ENVIRONMENT, MPESA_SHORTCODE and MPESA_PASSKEY environment variables, so that the credentials are kept
+// out of the source. The consumer key and secret are required, MPESA_ENVIRONMENT defaults to sandbox and the
+// passkey is required when a shortcode is set. The optional MPESA_CALLBACK_URL is the CallbackURL of Pay.
+func NewMpesaFromEnv() (*Mpesa, error) {
+	opts := &MpesaOpts{
+		ConsumerKey:    strings.TrimSpace(os.Getenv(envConsumerKey)),
+		ConsumerSecret: strings.TrimSpace(os.Getenv(envConsumerSecret)),
+		Environment:    Environment(strings.ToLower(strings.TrimSpace(os.Getenv(envEnvironment)))),
+		Shortcode:      strings.TrimSpace(os.Getenv(envShortcode)),
+		Passkey:        strings.TrimSpace(os.Getenv(envPasskey)),
+		CallbackURL:    strings.TrimSpace(os.Getenv(envCallbackURL)),
+	}
+
+	var missing []string
+	if opts.ConsumerKey == "" {
+		missing = append(missing, envConsumerKey)
+	}
+
+	if opts.ConsumerSecret == "" {
+		missing = append(missing, envConsumerSecret)
+	}
+
+	if opts.Shortcode != "" && opts.Passkey == "" {
+		missing = append(missing, envPasskey)
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("mpesa: missing environment variables %s", strings.Join(missing, ", "))
+	}
+
+	return NewMpesa(opts)
+}
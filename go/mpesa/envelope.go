@@ -0,0 +1,11 @@
+package main
+
+// ResponseHeader is the header the newer Daraja APIs, like Ratiba and the QR codes, wrap their responses in
+// instead of answering with flat fields
+type ResponseHeader struct {
+	ResponseRefID       string `json:"responseRefID"`
+	ResponseCode        string `json:"responseCode"`
+	ResponseDescription string `json:"responseDescription"`
+	CustomerMessage     string `json:"customerMessage"`
+	ResultDesc          string `json:"ResultDesc"`
+}
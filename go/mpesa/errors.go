@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrServiceUnavailable is returned when Safaricom cannot serve the request for now, typically during its
+// maintenance windows. The request can be retried later.
+var ErrServiceUnavailable = errors.New("mpesa: the service is unavailable")
+
+// MpesaError is the error envelope Safaricom sends back when it rejects a request
+type MpesaError struct {
+	RequestID    string `json:"requestId"`
+	ErrorCode    string `json:"errorCode"`
+	ErrorMessage string `json:"errorMessage"`
+}
+
+func (e *MpesaError) Error() string {
+	if e.RequestID == "" {
+		return fmt.Sprintf("mpesa: %s: %s", e.ErrorCode, e.ErrorMessage)
+	}
+
+	return fmt.Sprintf("mpesa: %s: %s (request %s)", e.ErrorCode, e.ErrorMessage, e.RequestID)
+}
+
+// httpErrorSnippetLength is how much of a body that is not JSON is kept in an HTTPError
+const httpErrorSnippetLength = 200
+
+// HTTPError is returned when Safaricom answers with something other than JSON, usually the HTML page of its
+// gateway while it is down or under maintenance, or with an error status and no error envelope. Those with a
+// success or server error status match ErrServiceUnavailable with errors.Is, client errors do not.
+type HTTPError struct {
+	StatusCode  int
+	ContentType string
+	// Snippet is the beginning of the body
+	Snippet string
+}
+
+// newHTTPError returns the HTTPError of the response
+func newHTTPError(resp *response) *HTTPError {
+	snippet := string(resp.body)
+	if len(snippet) > httpErrorSnippetLength {
+		snippet = snippet[:httpErrorSnippetLength] + "..."
+	}
+
+	return &HTTPError{StatusCode: resp.statusCode, ContentType: resp.header.Get("Content-Type"), Snippet: snippet}
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("mpesa: unexpected %d %q response: %q", e.StatusCode, e.ContentType, e.Snippet)
+}
+
+func (e *HTTPError) Unwrap() error {
+	if e.StatusCode >= http.StatusInternalServerError || isSuccessStatus(e.StatusCode) {
+		return ErrServiceUnavailable
+	}
+
+	return nil
+}
+
+// errorEnvelope returns the *MpesaError carried by the response body, or nil when the body is not an error
+// envelope.
+func errorEnvelope(body []byte) error {
+	envelope := new(MpesaError)
+	if err := json.Unmarshal(body, envelope); err != nil || envelope.ErrorCode == "" {
+		return nil
+	}
+
+	return envelope
+}
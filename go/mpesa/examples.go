@@ -1,33 +1,30 @@
 package main
 
 import (
-	"encoding/base64"
+	"context"
 	"fmt"
 	"log"
-	"time"
 )
 
 // stkPushExample is a sample of the M-Pesa Express (STK Push) request
 func stkPushExample() {
-	mpesa := NewMpesa(&MpesaOpts{
+	mpesa, err := NewMpesa(&MpesaOpts{
 		ConsumerKey:    "your-consumer-key-goes-here",
 		ConsumerSecret: "your-consumer-secret-goes-here",
-		BaseURL:        "https://sandbox.safaricom.co.ke",
+		Environment:    Sandbox,
 	})
+	if err != nil {
+		log.Fatalln(err)
+	}
 
-	// The expected format is YYYYMMDDHHmmss
-	timestamp := time.Now().Format("20060102150405")
 	shortcode, passkey := "your-business-short-code-goes-here", "your-pass-key-goes-here"
-
-	// base64 encoding of the shortcode + passkey + timestamp
-	passwordToEncode := fmt.Sprintf("%s%s%s", shortcode, passkey, timestamp)
-	password := base64.StdEncoding.EncodeToString([]byte(passwordToEncode))
+	password, timestamp := GenerateSTKPushPassword(shortcode, passkey)
 
 	response, err := mpesa.InitiateSTKPushRequest(&STKPushRequestBody{
 		BusinessShortCode: shortcode,
 		Password:          password,
 		Timestamp:         timestamp,
-		TransactionType:   "CustomerPayBillOnline",
+		TransactionType:   STKCustomerPayBillOnline,
 		Amount:            "10",                          // Amount to be charged when checking out
 		PartyA:            "your-phone-number-goes-here", // 2547XXXXXXXX
 		PartyB:            shortcode,
@@ -44,32 +41,110 @@ func stkPushExample() {
 	fmt.Printf("%+v\n", response)
 }
 
-// b2cRequestExample is a sample of the B2C API request
-func b2cRequestExample() {
-	mpesa := NewMpesa(&MpesaOpts{
+// payFromEnvExample is a sample of the M-Pesa Express (STK Push) request made with the credentials, shortcode
+// and passkey read from the MPESA_* environment variables
+func payFromEnvExample() {
+	mpesa, err := NewMpesaFromEnv()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	response, err := mpesa.Pay(context.Background(), "your-phone-number-goes-here", 10, "TEST", "Payment via STK push.")
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	fmt.Printf("%+v\n", response)
+}
+
+// stkPushBuyGoodsExample is a sample of the M-Pesa Express (STK Push) request paying into a till number
+func stkPushBuyGoodsExample() {
+	mpesa, err := NewMpesa(&MpesaOpts{
 		ConsumerKey:    "your-consumer-key-goes-here",
 		ConsumerSecret: "your-consumer-secret-goes-here",
-		BaseURL:        "https://sandbox.safaricom.co.ke",
+		Environment:    Sandbox,
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// The passkey is issued for the store number, which is different from the till number being paid
+	storeNumber, passkey := "your-store-number-goes-here", "your-pass-key-goes-here"
+	password, timestamp := GenerateSTKPushPassword(storeNumber, passkey)
+
+	response, err := mpesa.InitiateSTKPushRequest(&STKPushRequestBody{
+		BusinessShortCode: storeNumber,
+		Password:          password,
+		Timestamp:         timestamp,
+		TransactionType:   STKCustomerBuyGoodsOnline,
+		Amount:            "10",                          // Amount to be charged when checking out
+		PartyA:            "your-phone-number-goes-here", // 2547XXXXXXXX
+		PartyB:            "your-till-number-goes-here",
+		PhoneNumber:       "your-phone-number-goes-here",              // 2547XXXXXXXX
+		CallBackURL:       "your-endpoint-to-receive-the-callback-on", // https://
+		AccountReference:  "TEST",
+		TransactionDesc:   "Payment via STK push.",
 	})
 
-	securityCredentials, err := GenerateSecurityCredentials("your-initiator-password", false)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	fmt.Printf("%+v\n", response)
+}
+
+// b2cRequestExample is a sample of the B2C API request
+func b2cRequestExample() {
+	mpesa, err := NewMpesa(&MpesaOpts{
+		ConsumerKey:       "your-consumer-key-goes-here",
+		ConsumerSecret:    "your-consumer-secret-goes-here",
+		Environment:       Sandbox,
+		Initiator:         "your-initiator-name-goes-here",
+		InitiatorPassword: "your-initiator-password",
+		Certificate:       sandboxCertificate,
+	})
 	if err != nil {
 		log.Fatalln(err)
 	}
 
 	response, err := mpesa.InitiateB2CRequest(&B2CRequestBody{
-		InitiatorName:      "your-initiator-name-goes-here",
-		SecurityCredential: securityCredentials,
-		CommandID:          "BusinessPayment",
-		Amount:             "1",
-		PartyA:             "your-business-short-code-goes-here",
-		PartyB:             "your-phone-number-goes-here",
-		Remarks:            "Payment to customer",
-		QueueTimeOutURL:    "your-endpoint-to-receive-notifications-in-case-request-times-out",
-		ResultURL:          "your-endpoint-to-receive-the-notifications",
-		Occassion:          "Payment to customer",
+		CommandID:       B2CBusinessPayment,
+		Amount:          "1",
+		PartyA:          "your-business-short-code-goes-here",
+		PartyB:          "your-phone-number-goes-here",
+		Remarks:         "Payment to customer",
+		QueueTimeOutURL: "your-endpoint-to-receive-notifications-in-case-request-times-out",
+		ResultURL:       "your-endpoint-to-receive-the-notifications",
+		Occasion:        "Payment to customer",
+	})
+
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	fmt.Printf("%+v\n", response)
+}
+
+// stkPushShortcodeExample is a sample of the M-Pesa Express (STK Push) request using a registered shortcode
+func stkPushShortcodeExample() {
+	mpesa, err := NewMpesa(&MpesaOpts{
+		ConsumerKey:    "your-consumer-key-goes-here",
+		ConsumerSecret: "your-consumer-secret-goes-here",
+		Environment:    Sandbox,
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	mpesa.UseShortcode(&ShortcodeConfig{
+		Shortcode:        "your-business-short-code-goes-here",
+		Passkey:          "your-pass-key-goes-here",
+		CallbackURL:      "your-endpoint-to-receive-the-callback-on", // https://
+		AccountReference: "TEST",
+		TransactionDesc:  "Payment via STK push.",
 	})
 
+	response, err := mpesa.STK(10, "your-phone-number-goes-here") // 2547XXXXXXXX
 	if err != nil {
 		log.Fatalln(err)
 	}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// STKResult is the final result of an STK push flow. Callback is set when the result came from the callback, and
+// Status when it came from a status query instead.
+type STKResult struct {
+	Response    *STKPushRequestResponse
+	Callback    *STKPushCallbackResponse
+	Status      *STKPushQueryResponse
+	Correlation *Correlation
+}
+
+// RunSTKFlow initiates the STK push request and waits for its result, from the callback posted to a temporary
+// server listening on callbackAddr or from polling the status of the push, whichever comes first. The server
+// acknowledges the callbacks like a CallbackRouter and is shut down before returning. The CallBackURL of the
+// request must reach that server, for example through a tunnel when running locally, otherwise the result only
+// comes from polling. Use a ctx with a deadline, the flow waits until there is a result or ctx is done. This is
+// meant for sandbox testing and demos.
+func (m *Mpesa) RunSTKFlow(ctx context.Context, req *STKPushRequestBody, callbackAddr string) (*STKResult, error) {
+	listener, err := net.Listen("tcp", callbackAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	awaiter := NewSTKAwaiter()
+	router := NewCallbackRouter()
+	router.OnSTKCallbackAwaiter("/", awaiter)
+
+	server := &http.Server{Handler: router}
+
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	response, _, err := m.InitiateSTKPushRequestRaw(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := m.AwaitSTKResult(ctx, awaiter, response.CheckoutID(), 0)
+	if err != nil {
+		return &STKResult{Response: response}, err
+	}
+
+	result.Response = response
+	result.Correlation, _, err = m.correlations.Get(ctx, response.CheckoutID())
+
+	return result, err
+}
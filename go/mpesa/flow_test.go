@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// freeAddr returns a local address nothing listens on
+func freeAddr(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer listener.Close()
+
+	return listener.Addr().String()
+}
+
+func TestRunSTKFlowCallback(t *testing.T) {
+	s := NewMockServer()
+	defer s.Close()
+	s.Respond(stkQueryEndpoint.path, http.StatusInternalServerError, stkProcessingEnvelope)
+
+	m := newTestMpesa(t, s, MpesaOpts{})
+	addr := freeAddr(t)
+	callback := readCallback(t, "stk_success.json")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The callback is posted until it is acknowledged by a flow waiting for it
+	go func() {
+		for ctx.Err() == nil {
+			if resp, err := http.Post("http://"+addr+"/mpesa/stk", "application/json", strings.NewReader(callback)); err == nil {
+				resp.Body.Close()
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	result, err := m.RunSTKFlow(ctx, testSTKPushBody(), addr)
+	if err != nil {
+		t.Fatalf("RunSTKFlow() error = %v", err)
+	}
+
+	if result.Callback == nil || result.Callback.Body.StkCallback.ResultCode != ResultCodeSuccess {
+		t.Errorf("RunSTKFlow() = %+v, want the successful callback", result)
+	}
+
+	if result.Correlation == nil || result.Correlation.CheckoutRequestID != result.Response.CheckoutRequestID {
+		t.Errorf("Correlation = %+v, want the STK push correlation", result.Correlation)
+	}
+}
+
+func TestRunSTKFlowPolling(t *testing.T) {
+	s := NewMockServer()
+	defer s.Close()
+
+	m := newTestMpesa(t, s, MpesaOpts{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Nothing posts the callback, the result comes from the status query
+	result, err := m.RunSTKFlow(ctx, testSTKPushBody(), freeAddr(t))
+	if err != nil {
+		t.Fatalf("RunSTKFlow() error = %v", err)
+	}
+
+	if result.Status == nil || result.Status.ResultCode != "0" {
+		t.Errorf("RunSTKFlow() = %+v, want the status of the STK push", result)
+	}
+}
@@ -1,3 +1,8 @@
 module mpesa-golang
 
-go 1.16
+go 1.21
+
+require (
+	go.uber.org/goleak v1.3.0
+	golang.org/x/time v0.5.0
+)
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+// version is the version of the app sent in the default User-Agent
+const version = "0.1.0"
+
+// defaultUserAgent is the User-Agent of the requests when MpesaOpts.UserAgent is not set
+const defaultUserAgent = "go-mpesa/" + version
+
+type headersContextKey struct{}
+
+// WithHeader returns a copy of ctx that makes the app send the header with the requests made with it, for example
+// a correlation ID to quote to Safaricom support. It adds to the headers set by the previous calls. The headers
+// the app sets itself, like Authorization, are not overridden, except User-Agent.
+func WithHeader(ctx context.Context, key, value string) context.Context {
+	headers := requestHeaders(ctx).Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+
+	headers.Add(key, value)
+	return context.WithValue(ctx, headersContextKey{}, headers)
+}
+
+// requestHeaders returns the headers set on ctx with WithHeader
+func requestHeaders(ctx context.Context) http.Header {
+	headers, _ := ctx.Value(headersContextKey{}).(http.Header)
+	return headers
+}
+
+// setHeaders sets the User-Agent and the headers of the request context on the request
+func (m *Mpesa) setHeaders(req *http.Request) {
+	for key, values := range requestHeaders(req.Context()) {
+		if _, set := req.Header[key]; !set {
+			req.Header[key] = values
+		}
+	}
+
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", m.userAgent)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+)
+
+// ErrUnreachable is wrapped by the Ping errors of the token requests that did not reach Safaricom, because of a
+// DNS, connection or TLS failure
+var ErrUnreachable = errors.New("mpesa: Safaricom is unreachable")
+
+// Ping checks that the app can authenticate with Safaricom, for readiness probes. The cached access token is
+// used while it is valid, otherwise a new one is generated. Failures wrap ErrInvalidCredentials when the consumer
+// key and secret are rejected, ErrUnreachable when Safaricom could not be reached and ErrServiceUnavailable when
+// it answered with a server error or without an access token.
+func (m *Mpesa) Ping(ctx context.Context) error {
+	_, err := m.getAccessToken(ctx)
+
+	var urlErr *url.Error
+	if err != nil && ctx.Err() == nil && errors.As(err, &urlErr) {
+		return fmt.Errorf("%w: %w", ErrUnreachable, err)
+	}
+
+	return err
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestPing(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr error
+	}{
+		{name: "token generated", status: http.StatusOK, body: `{"access_token":"mock-access-token","expires_in":"3599"}`},
+		{name: "empty 500", status: http.StatusInternalServerError, wantErr: ErrServiceUnavailable},
+		{name: "empty token", status: http.StatusOK, body: `{}`, wantErr: ErrServiceUnavailable},
+		{name: "credentials rejected", status: http.StatusBadRequest, body: `{
+			"requestId": "3c4b-4d3a-9dd5-c8fe7d2d95a3",
+			"errorCode": "400.008.01",
+			"errorMessage": "Invalid Authentication passed"
+		}`, wantErr: ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMockServer()
+			defer s.Close()
+
+			s.Respond(oauthEndpoint.path, tt.status, tt.body)
+			m := newTestMpesa(t, s, MpesaOpts{})
+
+			err := m.Ping(context.Background())
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("Ping() error = %v", err)
+			}
+
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Ping() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPingUnreachable(t *testing.T) {
+	s := NewMockServer()
+	m := newTestMpesa(t, s, MpesaOpts{})
+	s.Close()
+
+	if err := m.Ping(context.Background()); !errors.Is(err, ErrUnreachable) {
+		t.Fatalf("Ping() error = %v, want ErrUnreachable", err)
+	}
+}
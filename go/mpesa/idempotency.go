@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// defaultIdempotencyWindow is how long an accepted STK push is reused when MpesaOpts.IdempotencyWindow is not set
+const defaultIdempotencyWindow = time.Hour
+
+// stkPushAttempt is an STK push sent with an idempotency key. It is in flight until done is closed.
+type stkPushAttempt struct {
+	done      chan struct{}
+	response  *STKPushRequestResponse
+	err       error
+	expiresAt time.Time
+}
+
+// InitiateSTKPushRequestIdempotent initiates the STK push once per key. Calls with a key whose push Safaricom
+// accepted within the idempotency window return the recorded response without charging the customer again,
+// and calls made while the push is in flight wait for it. A push that failed or was not accepted is not
+// recorded, so the call can be retried with the same key. Keys are kept in memory.
+func (m *Mpesa) InitiateSTKPushRequestIdempotent(key string, body *STKPushRequestBody) (*STKPushRequestResponse, error) {
+	return m.InitiateSTKPushRequestIdempotentWithContext(context.Background(), key, body)
+}
+
+// InitiateSTKPushRequestIdempotentWithContext is InitiateSTKPushRequestIdempotent with a context that cancels the
+// request
+func (m *Mpesa) InitiateSTKPushRequestIdempotentWithContext(ctx context.Context, key string, body *STKPushRequestBody) (*STKPushRequestResponse, error) {
+	if key == "" {
+		return nil, &ValidationError{Field: "key", Reason: "the idempotency key must not be empty"}
+	}
+
+	for {
+		m.mu.Lock()
+
+		attempt, ok := m.stkPushes[key]
+		if !ok || (!attempt.expiresAt.IsZero() && time.Now().After(attempt.expiresAt)) {
+			attempt = &stkPushAttempt{done: make(chan struct{})}
+			m.recordSTKPushAttempt(key, attempt)
+			m.mu.Unlock()
+
+			return m.sendSTKPushAttempt(ctx, key, attempt, body)
+		}
+
+		m.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-attempt.done:
+		}
+
+		if attempt.err == nil && attempt.response.Accepted() {
+			response := *attempt.response
+			return &response, nil
+		}
+	}
+}
+
+// recordSTKPushAttempt registers the attempt under key and forgets the expired ones. m.mu must be held.
+func (m *Mpesa) recordSTKPushAttempt(key string, attempt *stkPushAttempt) {
+	if m.stkPushes == nil {
+		m.stkPushes = make(map[string]*stkPushAttempt)
+	}
+
+	now := time.Now()
+	for k, a := range m.stkPushes {
+		if !a.expiresAt.IsZero() && now.After(a.expiresAt) {
+			delete(m.stkPushes, k)
+		}
+	}
+
+	m.stkPushes[key] = attempt
+}
+
+// sendSTKPushAttempt initiates the STK push of the attempt and keeps it for the idempotency window when it was
+// accepted
+func (m *Mpesa) sendSTKPushAttempt(ctx context.Context, key string, attempt *stkPushAttempt, body *STKPushRequestBody) (*STKPushRequestResponse, error) {
+	attempt.response, attempt.err = m.InitiateSTKPushRequestWithContext(ctx, body)
+
+	m.mu.Lock()
+	if attempt.err == nil && attempt.response.Accepted() {
+		attempt.expiresAt = time.Now().Add(m.idempotencyWindow)
+	} else {
+		delete(m.stkPushes, key)
+	}
+	m.mu.Unlock()
+
+	close(attempt.done)
+
+	return attempt.response, attempt.err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"crypto/sha256"
+	"time"
+)
+
+// cachedCredential is a security credential kept to avoid encrypting the initiator password on every request
+type cachedCredential struct {
+	credential string
+	expiresAt  time.Time
+}
+
+// fillInitiator sets the initiator name and security credential configured on the app on the request fields
+// that were left empty. The configured credential is only used for the configured initiator, a request naming
+// another initiator must carry its own credential.
+func (m *Mpesa) fillInitiator(initiator, securityCredential *string) error {
+	if *initiator == "" {
+		*initiator = m.initiator
+	}
+
+	if *securityCredential != "" {
+		return nil
+	}
+
+	if *initiator != m.initiator {
+		return &ValidationError{
+			Field:  "SecurityCredential",
+			Reason: "set it on the request, the credential configured on the app belongs to another initiator",
+		}
+	}
+
+	credential, err := m.initiatorSecurityCredential(*initiator)
+	if err != nil {
+		return err
+	}
+
+	*securityCredential = credential
+	return nil
+}
+
+// initiatorSecurityCredential returns the security credential configured on the app, encrypting the initiator
+// password with GenerateSecurityCredentials when no pre-encrypted credential was given. Encrypted credentials are
+// reused for MpesaOpts.SecurityCredentialTTL, keyed by the initiator and password they were generated for.
+func (m *Mpesa) initiatorSecurityCredential(initiator string) (string, error) {
+	if m.securityCredential != "" {
+		return m.securityCredential, nil
+	}
+
+	if m.initiatorPassword == "" {
+		return "", &ValidationError{
+			Field:  "SecurityCredential",
+			Reason: "set it on the request or configure the app with a credential or an initiator password",
+		}
+	}
+
+	if m.securityCredentialTTL <= 0 {
+		return m.GenerateSecurityCredentials(m.initiatorPassword)
+	}
+
+	key := sha256.Sum256([]byte(initiator + "\x00" + m.initiatorPassword))
+
+	m.mu.RLock()
+	cached, ok := m.credentials[key]
+	m.mu.RUnlock()
+
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.credential, nil
+	}
+
+	credential, err := m.GenerateSecurityCredentials(m.initiatorPassword)
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	if m.credentials == nil {
+		m.credentials = make(map[[sha256.Size]byte]cachedCredential)
+	}
+	m.credentials[key] = cachedCredential{credential: credential, expiresAt: time.Now().Add(m.securityCredentialTTL)}
+	m.mu.Unlock()
+
+	return credential, nil
+}
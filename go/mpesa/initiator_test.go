@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFillInitiator(t *testing.T) {
+	m := &Mpesa{initiator: "apiop", securityCredential: "configured-credential"}
+
+	tests := []struct {
+		name           string
+		initiator      string
+		credential     string
+		wantInitiator  string
+		wantCredential string
+		wantErr        bool
+	}{
+		{name: "empty initiator", wantInitiator: "apiop", wantCredential: "configured-credential"},
+		{name: "configured initiator", initiator: "apiop", wantInitiator: "apiop", wantCredential: "configured-credential"},
+		{name: "other initiator with its credential", initiator: "other", credential: "other-credential", wantInitiator: "other", wantCredential: "other-credential"},
+		{name: "other initiator without credential", initiator: "other", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			initiator, credential := tt.initiator, tt.credential
+
+			err := m.fillInitiator(&initiator, &credential)
+			if tt.wantErr {
+				var validationErr *ValidationError
+				if !errors.As(err, &validationErr) || validationErr.Field != "SecurityCredential" {
+					t.Fatalf("fillInitiator() error = %v, want a SecurityCredential ValidationError", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("fillInitiator() error = %v", err)
+			}
+
+			if initiator != tt.wantInitiator || credential != tt.wantCredential {
+				t.Errorf("fillInitiator() = %q, %q, want %q, %q", initiator, credential, tt.wantInitiator, tt.wantCredential)
+			}
+		})
+	}
+}
+
+func TestInitiatorSecurityCredentialDefaultsToEnvironmentCertificate(t *testing.T) {
+	for _, env := range []Environment{Sandbox, Production} {
+		m := &Mpesa{initiator: "apiop", initiatorPassword: "Safaricom999!*!", environment: env}
+
+		credential, err := m.initiatorSecurityCredential("apiop")
+		if err != nil {
+			t.Fatalf("initiatorSecurityCredential() error = %v", err)
+		}
+
+		if credential == "" {
+			t.Error("initiatorSecurityCredential() returned an empty credential")
+		}
+	}
+}
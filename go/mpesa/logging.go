@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// sensitiveFields are the fields masked out of the bodies before they are logged
+var sensitiveFields = map[string]bool{
+	"Password":           true,
+	"SecurityCredential": true,
+	"access_token":       true,
+}
+
+// maskedValue replaces the value of the sensitive fields in the logs
+const maskedValue = "****"
+
+type verboseContextKey struct{}
+
+// WithVerbose returns a copy of ctx that makes the app log the request and response bodies of the calls made
+// with it at info level, so one caller can be debugged without turning on debug logs for all of them. Secrets
+// are masked out of the bodies.
+func WithVerbose(ctx context.Context) context.Context {
+	return context.WithValue(ctx, verboseContextKey{}, true)
+}
+
+// isVerbose reports whether the bodies of the calls made with ctx should be logged
+func isVerbose(ctx context.Context) bool {
+	verbose, _ := ctx.Value(verboseContextKey{}).(bool)
+	return verbose
+}
+
+// maskBody returns the body with the values of the sensitive fields masked. Bodies that are not JSON are
+// returned as they are.
+func maskBody(body []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return string(body)
+	}
+
+	masked, err := json.Marshal(maskValue(v))
+	if err != nil {
+		return string(body)
+	}
+
+	return string(masked)
+}
+
+// maskValue masks the sensitive fields of the decoded JSON value
+func maskValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if sensitiveFields[key] {
+				value[key] = maskedValue
+				continue
+			}
+
+			value[key] = maskValue(field)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = maskValue(item)
+		}
+	}
+
+	return v
+}
+
+// requestBody returns a copy of the body of the request without consuming it
+func requestBody(req *http.Request) []byte {
+	if req.GetBody == nil {
+		return nil
+	}
+
+	body, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+
+	contents, _ := io.ReadAll(body)
+	return contents
+}
+
+// logRequest logs the call with its masked bodies. Calls are logged at debug level, or at info level when they
+// are made with a verbose context.
+func (m *Mpesa) logRequest(e endpoint, req *http.Request, resp *response, err error) {
+	ctx, level := req.Context(), slog.LevelDebug
+	if isVerbose(ctx) {
+		level = slog.LevelInfo
+	}
+
+	if !m.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []any{
+		slog.String("endpoint", e.name),
+		slog.String("method", req.Method),
+		slog.String("url", req.URL.String()),
+		slog.String("request_body", maskBody(requestBody(req))),
+	}
+
+	if resp != nil {
+		attrs = append(attrs, slog.Int("status", resp.statusCode), slog.String("response_body", maskBody(resp.body)))
+	}
+
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", err))
+	}
+
+	m.logger.Log(ctx, level, "mpesa: request", attrs...)
+}
@@ -2,57 +2,227 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
+	_ "embed"
 	"encoding/base64"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"golang.org/x/time/rate"
 )
 
-// Mpesa is an application that will be making a transaction
+// Mpesa is an application that will be making a transaction.
+//
+// An Mpesa is safe for concurrent use by multiple goroutines, a single instance is meant to be shared across
+// all the request handlers of a server. Any state kept on it must be guarded accordingly.
 type Mpesa struct {
 	consumerKey    string
 	consumerSecret string
 	baseURL        string
+	environment    Environment
 	client         *http.Client
+	userAgent      string
+	timeout        time.Duration
+	logger         *slog.Logger
+
+	businessShortCode string
+	passkey           string
+	callbackURL       string
+
+	initiator          string
+	securityCredential string
+	initiatorPassword  string
+	certificate        []byte
+
+	securityCredentialTTL time.Duration
+
+	limiter *rate.Limiter
+
+	maxRetries         int
+	retryBackoff       time.Duration
+	retryNonIdempotent bool
+	credentialsBackoff time.Duration
+	retries            atomic.Int64
+
+	onRequestComplete func(endpoint string, statusCode int, duration time.Duration, err error)
+
+	autoRefreshToken bool
+	tokenLifetime    atomic.Int64
+
+	tokens         TokenStore
+	correlations   CorrelationStore
+	correlationTTL time.Duration
+
+	idempotencyWindow time.Duration
+
+	countryCode             string
+	sanitizeTransactionDesc bool
+	billRefNumberPattern    *regexp.Regexp
+	strictDecoding          bool
+	dryRun                  bool
+
+	mu                       sync.RWMutex
+	shortcodes               map[string]*ShortcodeConfig
+	defaultShortcode         string
+	credentialsRejectedUntil time.Time
+	credentials              map[[sha256.Size]byte]cachedCredential
+	tokenRefresh             *tokenRefresh
+	tokenRefresher           *tokenRefresher
+	closed                   bool
+	stkPushes                map[string]*stkPushAttempt
 }
 
 // MpesaOpts stores all the configuration keys we need to set up a Mpesa app,
 type MpesaOpts struct {
 	ConsumerKey    string
 	ConsumerSecret string
-	BaseURL        string
+	// Environment selects the Safaricom base URL, it defaults to Sandbox
+	Environment Environment
+	// BaseURL overrides the base URL of the Environment, for example to run against a mock server
+	BaseURL string
+
+	// Shortcode, Passkey and CallbackURL are the paybill charged by Pay and STK, they are registered with
+	// UseShortcode when Shortcode is set. InitiateSTKPushRequest also uses them to fill the BusinessShortCode,
+	// Password, Timestamp and CallBackURL of the bodies that leave them empty.
+	Shortcode   string
+	Passkey     string
+	CallbackURL string
+
+	// UserAgent is the User-Agent of the requests sent to Safaricom. Defaults to "go-mpesa/<version>".
+	UserAgent string
+
+	// Initiator is the API operator username used by B2C requests that leave it empty
+	Initiator string
+	// SecurityCredential is the already encrypted password of Initiator, it is not used for the requests naming
+	// another initiator. When it is empty, InitiatorPassword is encrypted with the public key in Certificate instead.
+	SecurityCredential string
+	InitiatorPassword  string
+	// Certificate is the PEM encoded certificate Safaricom issued for the environment the app runs against.
+	// Defaults to the certificate shipped with the app for Environment.
+	Certificate []byte
+	// SecurityCredentialTTL is how long a security credential encrypted from InitiatorPassword is reused before
+	// the password is encrypted again. 0 encrypts it on every request.
+	SecurityCredentialTTL time.Duration
+
+	// Timeout limits every request, from connecting to reading the response body. Defaults to 10 seconds. It does
+	// not apply to the requests made with a context that has a deadline, which limits them instead.
+	Timeout time.Duration
+	// ConnectTimeout limits resolving and connecting to Safaricom's hosts. Defaults to 5 seconds.
+	ConnectTimeout time.Duration
+	// TLSHandshakeTimeout limits the TLS handshake once connected. Defaults to 5 seconds.
+	TLSHandshakeTimeout time.Duration
+
+	// RateLimit is how many requests per second are sent to Safaricom at most, retries and token requests
+	// included. Requests over the limit wait for their turn, or for their context to be done. 0 disables it.
+	RateLimit float64
+
+	// MaxRetries is how many times a request failing with a network or server error is retried, 0 disables
+	// retries. Only idempotent requests are retried unless RetryNonIdempotent is set.
+	MaxRetries int
+	// RetryBackoff is the delay before the first retry, it doubles on every following retry up to 30 seconds.
+	// Defaults to 500ms.
+	RetryBackoff time.Duration
+	// RetryNonIdempotent allows retrying requests that move money, like STK push and B2C. A request that failed
+	// may still have been processed by Safaricom, so retrying it can charge or pay a customer twice.
+	RetryNonIdempotent bool
+
+	// CredentialsBackoff is how long token generation fails fast with ErrInvalidCredentials, without calling
+	// Safaricom, once the consumer key and secret have been rejected. 0 disables the backoff.
+	CredentialsBackoff time.Duration
+
+	// OnRequestComplete is called after every request sent to Safaricom, retries included, for example to record
+	// metrics. The endpoint is a short label such as "stkpush" or "oauth", and the status code is 0 when no
+	// response was received.
+	OnRequestComplete func(endpoint string, statusCode int, duration time.Duration, err error)
+
+	// AutoRefreshToken starts, after the first access token is generated, a goroutine generating the next one
+	// about a minute before it expires, or a quarter of its lifetime for short lived tokens, so that no request
+	// waits for its token. Close stops it.
+	AutoRefreshToken bool
+
+	// TokenStore keeps the access token, share one between processes to reuse the same token. Defaults to a
+	// MemoryTokenStore.
+	TokenStore TokenStore
+	// CorrelationStore keeps the details of the initiated STK pushes to match their callbacks. Defaults to a
+	// MemoryCorrelationStore.
+	CorrelationStore CorrelationStore
+	// CorrelationTTL is how long the correlations are kept. Defaults to 24 hours.
+	CorrelationTTL time.Duration
+	// IdempotencyWindow is how long InitiateSTKPushRequestIdempotent reuses an accepted STK push for its key.
+	// Defaults to 1 hour.
+	IdempotencyWindow time.Duration
+
+	// DefaultCountryCode is the country code given to local phone numbers like 07XXXXXXXX. Defaults to "254".
+	DefaultCountryCode string
+
+	// SanitizeTransactionDesc strips the characters Safaricom rejects from STK push TransactionDesc values
+	// instead of failing the request with a ValidationError.
+	SanitizeTransactionDesc bool
+
+	// BillRefNumberPattern is the format of the account numbers of the paybill. SimulateC2BTransaction rejects
+	// the paybill payments whose BillRefNumber does not match it, and the OnC2BValidation handlers of the routers
+	// returned by NewCallbackRouter reject them with C2BRejectInvalidAccountNumber. nil accepts any account number.
+	BillRefNumberPattern *regexp.Regexp
+
+	// StrictDecoding reports, through the Logger, the fields of Safaricom responses that the response structs
+	// do not know about. The requests still succeed, this is an early warning of API changes.
+	StrictDecoding bool
+
+	// DryRun makes every request return a *DryRunError holding the request that would have been sent, headers
+	// and body included, instead of sending it. The access token is taken from the TokenStore, or replaced by a
+	// placeholder, so Safaricom is never called. AccessToken and Ping return the *DryRunError of the token
+	// request when the TokenStore holds no valid token.
+	DryRun bool
+
+	// Logger receives the diagnostics of the app, every request is logged at debug level with its secrets masked.
+	// Nothing is logged when it is not set.
+	Logger *slog.Logger
 }
 
 // MpesaAccessTokenResponse is the response sent back by Safaricom when we make a request to generate a token
 type MpesaAccessTokenResponse struct {
 	AccessToken  string `json:"access_token"`
 	ExpiresIn    string `json:"expires_in"`
+	Scope        string `json:"scope,omitempty"`
 	RequestID    string `json:"requestId"`
 	ErrorCode    string `json:"errorCode"`
 	ErrorMessage string `json:"errorMessage"`
 }
 
-// STKPushRequestBody is the body with the parameters to be used to initiate an STK push request
+// STKPushRequestBody is the body with the parameters to be used to initiate an STK push request.
+//
+// TransactionType is STKCustomerPayBillOnline or STKCustomerBuyGoodsOnline, other values are rejected. Paybill
+// payments have PartyB set to the BusinessShortCode. Buy goods payments have BusinessShortCode set to the store
+// number the passkey was issued for and PartyB set to the till number being paid.
 type STKPushRequestBody struct {
-	BusinessShortCode string `json:"BusinessShortCode"`
-	Password          string `json:"Password"`
-	Timestamp         string `json:"Timestamp"`
-	TransactionType   string `json:"TransactionType"`
-	Amount            string `json:"Amount"`
-	PartyA            string `json:"PartyA"`
-	PartyB            string `json:"PartyB"`
-	PhoneNumber       string `json:"PhoneNumber"`
-	CallBackURL       string `json:"CallBackURL"`
-	AccountReference  string `json:"AccountReference"`
-	TransactionDesc   string `json:"TransactionDesc"`
+	BusinessShortCode string             `json:"BusinessShortCode"`
+	Password          string             `json:"Password"`
+	Timestamp         string             `json:"Timestamp"`
+	TransactionType   STKTransactionType `json:"TransactionType"`
+	Amount            string             `json:"Amount"` // Whole shillings, e.g. "10"
+	PartyA            string             `json:"PartyA"`
+	PartyB            string             `json:"PartyB"`
+	PhoneNumber       string             `json:"PhoneNumber"`
+	CallBackURL       string             `json:"CallBackURL"`
+	AccountReference  string             `json:"AccountReference"`
+	TransactionDesc   string             `json:"TransactionDesc"`
 }
 
 // STKPushRequestResponse is the response sent back after initiating an STK push request.
@@ -87,16 +257,28 @@ type STKPushCallbackResponse struct {
 
 // B2CRequestBody is the body with the parameters to be used to initiate a B2C request
 type B2CRequestBody struct {
-	InitiatorName      string `json:"InitiatorName"`
-	SecurityCredential string `json:"SecurityCredential"`
-	CommandID          string `json:"CommandID"`
-	Amount             string `json:"Amount"`
-	PartyA             string `json:"PartyA"`
-	PartyB             string `json:"PartyB"`
-	Remarks            string `json:"Remarks"`
-	QueueTimeOutURL    string `json:"QueueTimeOutURL"`
-	ResultURL          string `json:"ResultURL"`
-	Occassion          string `json:"Occassion"`
+	InitiatorName      string     `json:"InitiatorName"`
+	SecurityCredential string     `json:"SecurityCredential"`
+	CommandID          B2CCommand `json:"CommandID"`
+	Amount             string     `json:"Amount"` // Whole shillings, e.g. "10"
+	PartyA             string     `json:"PartyA"`
+	PartyB             string     `json:"PartyB"`
+	Remarks            string     `json:"Remarks"`
+	QueueTimeOutURL    string     `json:"QueueTimeOutURL"`
+	ResultURL          string     `json:"ResultURL"`
+	Occasion           string     `json:"-"` // Optional, sent as the "Occassion" Safaricom expects
+	// Deprecated: use Occasion, Occassion is only sent when Occasion is empty.
+	Occassion string `json:"Occassion,omitempty"`
+}
+
+// MarshalJSON writes Occasion under the misspelled "Occassion" key Safaricom expects
+func (b B2CRequestBody) MarshalJSON() ([]byte, error) {
+	type plain B2CRequestBody
+	if b.Occasion != "" {
+		b.Occassion = b.Occasion
+	}
+
+	return json.Marshal(plain(b))
 }
 
 // B2CRequestResponse is the response sent back after initiating a B2C request.
@@ -110,49 +292,214 @@ type B2CRequestResponse struct {
 	ErrorMessage             string `json:"errorMessage"`
 }
 
-// B2CCallbackResponse has the results of the callback data sent once we successfully make a B2C request.
-type B2CCallbackResponse struct {
-	Result struct {
-		ResultType               int    `json:"ResultType"`
-		ResultCode               int    `json:"ResultCode"`
-		ResultDesc               string `json:"ResultDesc"`
-		OriginatorConversationID string `json:"OriginatorConversationID"`
-		ConversationID           string `json:"ConversationID"`
-		TransactionID            string `json:"TransactionID"`
-		ResultParameters         struct {
-			ResultParameter []struct {
-				Key   string      `json:"Key"`
-				Value interface{} `json:"Value"`
-			} `json:"ResultParameter"`
-		} `json:"ResultParameters"`
-		ReferenceData struct {
-			ReferenceItem struct {
-				Key   string `json:"Key"`
-				Value string `json:"Value"`
-			} `json:"ReferenceItem"`
-		} `json:"ReferenceData"`
-	} `json:"Result"`
-}
-
-// NewMpesa sets up and returns an instance of Mpesa
-func NewMpesa(m *MpesaOpts) *Mpesa {
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-	}
-
-	return &Mpesa{
+// B2CCallbackResponse is the former name of B2CResultCallback.
+//
+// Deprecated: use B2CResultCallback.
+type B2CCallbackResponse = B2CResultCallback
+
+// NewMpesa sets up and returns an instance of Mpesa. An error is returned when the consumer key or secret is
+// missing, or the base URL is invalid.
+func NewMpesa(m *MpesaOpts) (*Mpesa, error) {
+	if strings.TrimSpace(m.ConsumerKey) == "" {
+		return nil, errors.New("mpesa: ConsumerKey is required")
+	}
+
+	if strings.TrimSpace(m.ConsumerSecret) == "" {
+		return nil, errors.New("mpesa: ConsumerSecret is required")
+	}
+
+	baseURL, err := m.Environment.baseURL()
+	if m.BaseURL != "" {
+		baseURL, err = m.BaseURL, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if baseURL, err = canonicalBaseURL(baseURL); err != nil {
+		return nil, err
+	}
+
+	client := newHTTPClient(m)
+
+	logger := m.Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	tokens := m.TokenStore
+	if tokens == nil {
+		tokens = NewMemoryTokenStore()
+	}
+
+	correlations := m.CorrelationStore
+	if correlations == nil {
+		correlations = NewMemoryCorrelationStore()
+	}
+
+	correlationTTL := m.CorrelationTTL
+	if correlationTTL <= 0 {
+		correlationTTL = defaultCorrelationTTL
+	}
+
+	idempotencyWindow := m.IdempotencyWindow
+	if idempotencyWindow <= 0 {
+		idempotencyWindow = defaultIdempotencyWindow
+	}
+
+	var limiter *rate.Limiter
+	if m.RateLimit > 0 {
+		limiter = rate.NewLimiter(rate.Limit(m.RateLimit), 1)
+	}
+
+	userAgent := m.UserAgent
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+
+	countryCode := strings.TrimPrefix(m.DefaultCountryCode, "+")
+	if countryCode == "" {
+		countryCode = defaultCountryCode
+	}
+
+	mpesa := &Mpesa{
 		consumerKey:    m.ConsumerKey,
 		consumerSecret: m.ConsumerSecret,
-		baseURL:        m.BaseURL,
+		baseURL:        baseURL,
+		environment:    m.Environment,
 		client:         client,
+		userAgent:      userAgent,
+		timeout:        orDefault(m.Timeout, defaultTimeout),
+		logger:         logger,
+
+		businessShortCode: m.Shortcode,
+		passkey:           m.Passkey,
+		callbackURL:       m.CallbackURL,
+
+		initiator:          m.Initiator,
+		securityCredential: m.SecurityCredential,
+		initiatorPassword:  m.InitiatorPassword,
+		certificate:        m.Certificate,
+
+		securityCredentialTTL: m.SecurityCredentialTTL,
+
+		limiter: limiter,
+
+		maxRetries:         m.MaxRetries,
+		retryBackoff:       m.RetryBackoff,
+		retryNonIdempotent: m.RetryNonIdempotent,
+		credentialsBackoff: m.CredentialsBackoff,
+
+		onRequestComplete: m.OnRequestComplete,
+
+		autoRefreshToken: m.AutoRefreshToken,
+
+		tokens:         tokens,
+		correlations:   correlations,
+		correlationTTL: correlationTTL,
+
+		idempotencyWindow: idempotencyWindow,
+
+		countryCode:             countryCode,
+		sanitizeTransactionDesc: m.SanitizeTransactionDesc,
+		billRefNumberPattern:    m.BillRefNumberPattern,
+		strictDecoding:          m.StrictDecoding,
+		dryRun:                  m.DryRun,
+	}
+
+	if m.Shortcode != "" {
+		mpesa.UseShortcode(&ShortcodeConfig{Shortcode: m.Shortcode, Passkey: m.Passkey, CallbackURL: m.CallbackURL})
+	}
+
+	return mpesa, nil
+}
+
+// Close releases the resources of the app: the background token refresher is stopped, the encrypted security
+// credentials are forgotten and the idle connections are closed. The TokenStore is left as it is, since it may be
+// shared with other processes still using its token. It is safe to call more than once and always returns nil for
+// now.
+func (m *Mpesa) Close() error {
+	m.mu.Lock()
+	m.closed = true
+	refresher := m.tokenRefresher
+	m.tokenRefresher = nil
+	m.credentials = nil
+	m.mu.Unlock()
+
+	if refresher != nil {
+		refresher.stop()
+	}
+
+	m.client.CloseIdleConnections()
+
+	return nil
+}
+
+// response holds the parts of a http response the app needs once the body has been read
+type response struct {
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+// makeRequest performs all the http requests for the specific app. Network errors and server errors are retried
+// with an exponential backoff when the endpoint allows it.
+func (m *Mpesa) makeRequest(e endpoint, req *http.Request) (*response, error) {
+	attempts := m.maxAttempts(e)
+	m.setHeaders(req)
+
+	for attempt := 1; ; attempt++ {
+		if err := m.waitForRateLimit(req.Context()); err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		resp, retry, err := m.sendRequest(req)
+		m.logRequest(e, req, resp, err)
+
+		if m.onRequestComplete != nil {
+			statusCode := 0
+			if resp != nil {
+				statusCode = resp.statusCode
+			}
+
+			m.onRequestComplete(e.name, statusCode, time.Since(start), err)
+		}
+
+		if !retry || attempt >= attempts {
+			return resp, err
+		}
+
+		if err := m.waitBeforeRetry(req.Context(), attempt); err != nil {
+			return nil, err
+		}
+
+		m.retries.Add(1)
+
+		req = req.Clone(req.Context())
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
 	}
 }
 
-// makeRequest performs all the http requests for the specific app
-func (m *Mpesa) makeRequest(req *http.Request) ([]byte, error) {
+// sendRequest sends the request once and reports whether the failure, if any, is worth retrying
+func (m *Mpesa) sendRequest(req *http.Request) (*response, bool, error) {
+	// The caller's deadline takes precedence over the timeout of the app, whether it is shorter or longer
+	parent := req.Context()
+	if _, ok := parent.Deadline(); !ok {
+		ctx, cancel := context.WithTimeout(parent, m.timeout)
+		defer cancel()
+
+		req = req.WithContext(ctx)
+	}
+
 	resp, err := m.client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, parent.Err() == nil, err
 	}
 
 	defer func(Body io.ReadCloser) {
@@ -162,196 +509,396 @@ func (m *Mpesa) makeRequest(req *http.Request) ([]byte, error) {
 	body, err := io.ReadAll(resp.Body)
 
 	if err != nil {
-		return nil, err
+		return nil, true, err
+	}
+
+	r := &response{
+		statusCode: resp.StatusCode,
+		header:     resp.Header,
+		body:       body,
 	}
 
-	return body, nil
+	// Safaricom answers with an HTML page, often with a 200 status, while under maintenance. Other client errors,
+	// like the text page of a 404, fail the same way on every attempt.
+	retry := resp.StatusCode >= http.StatusInternalServerError || (isSuccessStatus(resp.StatusCode) && len(body) > 0 && !r.isJSON())
+	return r, retry, nil
 }
 
-// generateAccessToken sends a http request to generate new access token
-func (m *Mpesa) generateAccessToken() (*MpesaAccessTokenResponse, error) {
-	url := fmt.Sprintf("%s/oauth/v1/generate?grant_type=client_credentials", m.baseURL)
+// isSuccessStatus reports whether the http status code is a 2xx
+func isSuccessStatus(code int) bool {
+	return code >= http.StatusOK && code < http.StatusMultipleChoices
+}
+
+// isJSON reports whether the response body is JSON. The start of the body is checked rather than the content
+// type, which Safaricom does not always set and which does not hold for the error pages of its gateway.
+func (r *response) isJSON() bool {
+	body := bytes.TrimSpace(r.body)
+	return len(body) > 0 && (body[0] == '{' || body[0] == '[')
+}
+
+// decodeResponse unmarshals the response body into v. In strict decoding mode the fields that v does not
+// declare are logged together so that changes to Safaricom's responses do not go unnoticed.
+func (m *Mpesa) decodeResponse(body []byte, v interface{}) error {
+	if err := json.Unmarshal(body, v); err != nil {
+		return err
+	}
+
+	if !m.strictDecoding {
+		return nil
+	}
+
+	if fields := unknownFields(body, v); len(fields) > 0 {
+		m.logger.Warn("mpesa: response has fields that are not decoded", "type", fmt.Sprintf("%T", v), "fields", fields)
+	}
+
+	return nil
+}
+
+// generateAccessToken sends a http request to generate new access token. Error envelopes are returned as an
+// *MpesaError, the other responses that are not a success or carry no access token fail as well so that an empty
+// token is never cached.
+func (m *Mpesa) generateAccessToken(ctx context.Context) (*MpesaAccessTokenResponse, error) {
+	tokenURL, err := m.tokenURL()
+	if err != nil {
+		return nil, err
+	}
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL, nil)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := m.checkCredentialsBackoff(); err != nil {
+		return nil, err
+	}
+
 	req.SetBasicAuth(m.consumerKey, m.consumerSecret)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := m.makeRequest(req)
+	if m.dryRun {
+		m.setHeaders(req)
+		return nil, &DryRunError{Endpoint: oauthEndpoint.name, Request: req}
+	}
+
+	resp, err := m.makeRequest(oauthEndpoint, req)
 	if err != nil {
 		return nil, err
 	}
 
+	if len(resp.body) > 0 && !resp.isJSON() {
+		return nil, newHTTPError(resp)
+	}
+
 	accessTokenResponse := new(MpesaAccessTokenResponse)
-	if err := json.Unmarshal(resp, &accessTokenResponse); err != nil {
+	if len(resp.body) > 0 {
+		if err := m.decodeResponse(resp.body, accessTokenResponse); err != nil {
+			return nil, err
+		}
+	}
+
+	if credentialsRejected(resp.statusCode, accessTokenResponse) {
+		return nil, m.rejectCredentials(accessTokenResponse)
+	}
+
+	if err := errorEnvelope(resp.body); err != nil {
 		return nil, err
 	}
 
+	if !isSuccessStatus(resp.statusCode) {
+		return nil, newHTTPError(resp)
+	}
+
+	if accessTokenResponse.AccessToken == "" {
+		return nil, fmt.Errorf("%w: the oauth response has no access_token", ErrServiceUnavailable)
+	}
+
 	return accessTokenResponse, nil
 }
 
-// setupHttpRequestWithAuth is a helper method aimed to create a http request adding
-// the Authorization Bearer header with the access token for the Mpesa app.
-func (m *Mpesa) setupHttpRequestWithAuth(method, url string, body []byte) (*http.Request, error) {
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
+// authorizedRequest creates a http request with the Authorization Bearer header of the cached access token. Every
+// endpoint creates its requests with it, through send, so a token is only generated when the cached one expires.
+func (m *Mpesa) authorizedRequest(ctx context.Context, method, url string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(body))
 	if err != nil {
 		return nil, err
 	}
 
-	accessTokenResponse, err := m.generateAccessToken()
-	if err != nil {
+	var accessToken *AccessToken
+	if m.dryRun {
+		accessToken = m.dryRunToken(ctx)
+	} else if accessToken, err = m.getAccessToken(ctx); err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessTokenResponse.AccessToken))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken.Token))
 
 	return req, nil
 }
 
-// InitiateSTKPushRequest makes a http request performing an STK push request
-func (m *Mpesa) InitiateSTKPushRequest(body *STKPushRequestBody) (*STKPushRequestResponse, error) {
-	url := fmt.Sprintf("%s/mpesa/stkpush/v1/processrequest", m.baseURL)
-
-	requestBody, err := json.Marshal(body)
+// send posts body as JSON to the endpoint with the access token and decodes the response into v. The raw
+// response body is returned with the error whenever Safaricom answered. Error envelopes are returned as an
+// *MpesaError and bodies that are not JSON as an *HTTPError.
+func (m *Mpesa) send(ctx context.Context, e endpoint, body, v interface{}) ([]byte, error) {
+	url, err := m.endpointURL(e)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(requestBody))
+	requestBody, err := json.Marshal(body)
 	if err != nil {
 		return nil, err
 	}
 
-	accessTokenResponse, err := m.generateAccessToken()
+	req, err := m.authorizedRequest(ctx, http.MethodPost, url, requestBody)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessTokenResponse.AccessToken))
+	if m.dryRun {
+		m.setHeaders(req)
+		return nil, &DryRunError{Endpoint: e.name, Request: req, Body: requestBody}
+	}
 
-	resp, err := m.makeRequest(req)
+	resp, err := m.makeRequest(e, req)
 	if err != nil {
 		return nil, err
 	}
 
-	stkPushResponse := new(STKPushRequestResponse)
-	if err := json.Unmarshal(resp, &stkPushResponse); err != nil {
-		return nil, err
+	storeRawResponse(ctx, resp.body)
+
+	if !resp.isJSON() {
+		return resp.body, newHTTPError(resp)
+	}
+
+	if err := m.decodeResponse(resp.body, v); err != nil {
+		return resp.body, err
+	}
+
+	if err := errorEnvelope(resp.body); err != nil {
+		return resp.body, err
 	}
 
-	return stkPushResponse, nil
+	return resp.body, nil
 }
 
-func httpServer() {
-	stkPushCallbackHandler := func(w http.ResponseWriter, req *http.Request) {
-		payload := new(STKPushCallbackResponse)
+// InitiateSTKPushRequest makes a http request performing an STK push request. When Safaricom answers with an
+// error envelope or a ResponseCode other than "0", it is returned as an *MpesaError carrying the
+// ResponseDescription, so a response without a CheckoutRequestID is never returned as a success.
+func (m *Mpesa) InitiateSTKPushRequest(body *STKPushRequestBody) (*STKPushRequestResponse, error) {
+	return m.InitiateSTKPushRequestWithContext(context.Background(), body)
+}
 
-		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
-			log.Fatalln(err)
-		}
+// InitiateSTKPushRequestWithContext is InitiateSTKPushRequest with a context that cancels the request, including
+// the access token generation and the retries.
+func (m *Mpesa) InitiateSTKPushRequestWithContext(ctx context.Context, body *STKPushRequestBody) (*STKPushRequestResponse, error) {
+	stkPushResponse, _, err := m.InitiateSTKPushRequestRaw(ctx, body)
+	return stkPushResponse, err
+}
 
-		fmt.Printf("%+v\n", payload)
+// InitiateSTKPushRequestRaw performs an STK push request and returns the decoded response together with the
+// raw response body exactly as it was sent back by Safaricom. The empty shortcode, password and callback fields
+// are filled from MpesaOpts, and PartyA and PhoneNumber are normalized to the international format before the
+// body is validated. Responses that did not accept the push, like a ResponseCode other than "0", are returned
+// as an *MpesaError.
+func (m *Mpesa) InitiateSTKPushRequestRaw(ctx context.Context, body *STKPushRequestBody) (*STKPushRequestResponse, []byte, error) {
+	var err error
+
+	stkPushBody := *body
+	m.fillSTKPush(&stkPushBody, time.Now())
+
+	if stkPushBody.PartyA, err = m.normalizePhoneNumber("PartyA", stkPushBody.PartyA); err != nil {
+		return nil, nil, err
+	}
+
+	if stkPushBody.PhoneNumber, err = m.normalizePhoneNumber("PhoneNumber", stkPushBody.PhoneNumber); err != nil {
+		return nil, nil, err
+	}
 
-		fmt.Printf("Result Code: %d\n", payload.Body.StkCallback.ResultCode)
-		fmt.Printf("Result Description: %s\n", payload.Body.StkCallback.ResultDesc)
+	if stkPushBody.Amount, err = stkPushEndpoint.amount.normalize(stkPushBody.Amount); err != nil {
+		return nil, nil, err
 	}
 
-	b2cRequestCallbackHandler := func(w http.ResponseWriter, req *http.Request) {
-		payload := new(B2CCallbackResponse)
+	if err := stkPushBody.validate(m.sanitizeTransactionDesc); err != nil {
+		return nil, nil, err
+	}
+
+	stkPushResponse := new(STKPushRequestResponse)
+	raw, err := m.send(ctx, stkPushEndpoint, stkPushBody, stkPushResponse)
+	if err != nil {
+		return nil, raw, err
+	}
 
-		if err := json.NewDecoder(req.Body).Decode(&payload); err != nil {
-			log.Fatalln(err)
+	if err := stkPushResponse.Err(); err != nil {
+		return nil, raw, err
+	}
+
+	m.recordCorrelation(ctx, &stkPushBody, stkPushResponse)
+
+	return stkPushResponse, raw, nil
+}
+
+func httpServer() {
+	router := NewCallbackRouter()
+
+	router.OnDecodeError(func(req *http.Request, err error) {
+		log.Printf("[!] Malformed callback posted to %s: %v", req.URL.Path, err)
+	})
+
+	router.OnSTKCallback("/stk-push-callback", func(payload *STKPushCallbackResponse) {
+		fmt.Printf("%+v\n", payload)
+
+		if !payload.IsSuccessful() {
+			fmt.Printf(
+				"Payment failed: %s (%s)\n",
+				ResultCodeDescription(payload.Body.StkCallback.ResultCode), payload.FailureReason(),
+			)
+			return
 		}
 
+		amount, _ := payload.Amount()
+		receipt, _ := payload.MpesaReceiptNumber()
+		fmt.Printf("Payment of %.2f received, receipt %s\n", amount, receipt)
+	})
+
+	router.OnB2CResult("/b2c-callback", func(payload *B2CResultCallback) {
 		fmt.Printf("%+v\n", payload)
 
 		fmt.Printf("Result Code: %d\n", payload.Result.ResultCode)
 		fmt.Printf("Result Description: %s\n", payload.Result.ResultDesc)
-	}
+	})
 
 	addr := ":8080"
-	http.HandleFunc("/stk-push-callback", stkPushCallbackHandler)
-	http.HandleFunc("/b2c-callback", b2cRequestCallbackHandler)
 
 	log.Printf("[*] Server started and running on port %s", addr)
-	log.Fatal(http.ListenAndServe(addr, nil))
+	log.Fatal(http.ListenAndServe(addr, router))
 }
 
-// GenerateSecurityCredentials returns the encrypted password using the public key of the specified environment
-func GenerateSecurityCredentials(password string, isOnProduction bool) (string, error) {
-	path := "./certificates/production.cer"
+var (
+	//go:embed certificates/sandbox.cer
+	sandboxCertificate []byte
+	//go:embed certificates/production.cer
+	productionCertificate []byte
+)
 
-	if !isOnProduction {
-		path = "./certificates/sandbox.cer"
+// GenerateSecurityCredentials encrypts the initiator password with the public key of cert, the certificate
+// Safaricom issued for the environment. The certificate may be PEM encoded or raw DER.
+func GenerateSecurityCredentials(password string, cert []byte) (string, error) {
+	der := cert
+	if block, _ := pem.Decode(cert); block != nil {
+		der = block.Bytes
 	}
 
-	f, err := os.Open(path)
+	certificate, err := x509.ParseCertificate(der)
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("mpesa: invalid certificate: %w", err)
 	}
 
-	defer func(f *os.File) {
-		_ = f.Close()
-	}(f)
+	rsaPublicKey, ok := certificate.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return "", errors.New("mpesa: the certificate does not hold an RSA public key")
+	}
 
-	contents, err := io.ReadAll(f)
+	encryptedPayload, err := rsa.EncryptPKCS1v15(rand.Reader, rsaPublicKey, []byte(password))
 	if err != nil {
 		return "", err
 	}
 
-	block, _ := pem.Decode(contents)
-
-	var cert *x509.Certificate
+	return base64.StdEncoding.EncodeToString(encryptedPayload), nil
+}
 
-	cert, err = x509.ParseCertificate(block.Bytes)
-	if err != nil {
-		return "", err
-	}
+// SandboxSecurityCredentials encrypts the initiator password with the sandbox certificate shipped with the app
+func SandboxSecurityCredentials(password string) (string, error) {
+	return GenerateSecurityCredentials(password, sandboxCertificate)
+}
 
-	rsaPublicKey := cert.PublicKey.(*rsa.PublicKey)
-	reader := rand.Reader
+// ProductionSecurityCredentials encrypts the initiator password with the production certificate shipped with the
+// app. Use GenerateSecurityCredentials with the current certificate when Safaricom rotates it.
+func ProductionSecurityCredentials(password string) (string, error) {
+	return GenerateSecurityCredentials(password, productionCertificate)
+}
 
-	encryptedPayload, err := rsa.EncryptPKCS1v15(reader, rsaPublicKey, []byte(password))
-	if err != nil {
-		return "", err
+// GenerateSecurityCredentials encrypts the initiator password with MpesaOpts.Certificate, or when it is not set
+// with the certificate shipped for the Environment the app was created for
+func (m *Mpesa) GenerateSecurityCredentials(password string) (string, error) {
+	cert := m.certificate
+	if len(cert) == 0 {
+		cert = sandboxCertificate
+		if m.environment == Production {
+			cert = productionCertificate
+		}
 	}
 
-	securityCredentials := base64.StdEncoding.EncodeToString(encryptedPayload)
-	return securityCredentials, nil
+	return GenerateSecurityCredentials(password, cert)
 }
 
-// InitiateB2CRequest makes a http request performing a B2C payment request.
+// InitiateB2CRequest makes a http request performing a B2C payment request. When Safaricom answers with an
+// error envelope, it is returned as an *MpesaError.
 func (m *Mpesa) InitiateB2CRequest(body *B2CRequestBody) (*B2CRequestResponse, error) {
-	url := fmt.Sprintf("%s/mpesa/b2c/v1/paymentrequest", m.baseURL)
+	return m.InitiateB2CRequestWithContext(context.Background(), body)
+}
 
-	requestBody, err := json.Marshal(body)
-	if err != nil {
-		return nil, err
+// InitiateB2CRequestWithContext is InitiateB2CRequest with a context that cancels the request, including the
+// access token generation and the retries.
+func (m *Mpesa) InitiateB2CRequestWithContext(ctx context.Context, body *B2CRequestBody) (*B2CRequestResponse, error) {
+	b2cResponse, _, err := m.InitiateB2CRequestRaw(ctx, body)
+	return b2cResponse, err
+}
+
+// InitiateB2CRequestRaw performs a B2C payment request and returns the decoded response together with the
+// raw response body exactly as it was sent back by Safaricom.
+func (m *Mpesa) InitiateB2CRequestRaw(ctx context.Context, body *B2CRequestBody) (*B2CRequestResponse, []byte, error) {
+	b2cBody := *body
+
+	var err error
+	if b2cBody.Amount, err = b2cEndpoint.amount.normalize(b2cBody.Amount); err != nil {
+		return nil, nil, err
 	}
 
-	req, err := m.setupHttpRequestWithAuth(http.MethodPost, url, requestBody)
+	if err := m.fillInitiator(&b2cBody.InitiatorName, &b2cBody.SecurityCredential); err != nil {
+		return nil, nil, err
+	}
+
+	if err := b2cBody.validate(m.logger); err != nil {
+		return nil, nil, err
+	}
+
+	b2cResponse := new(B2CRequestResponse)
+	raw, err := m.send(ctx, b2cEndpoint, b2cBody, b2cResponse)
 	if err != nil {
-		return nil, err
+		return nil, raw, err
 	}
 
-	resp, err := m.makeRequest(req)
+	return b2cResponse, raw, nil
+}
+
+// replayCallbackFile posts the callback payload saved at path to url the given number of times and prints the
+// response statuses
+func replayCallbackFile(path, url string, times int, interval time.Duration) {
+	payload, err := os.ReadFile(path)
 	if err != nil {
-		return nil, err
+		log.Fatalln(err)
 	}
 
-	b2cResponse := new(B2CRequestResponse)
-	if err := json.Unmarshal(resp, &b2cResponse); err != nil {
-		return nil, err
+	statusCodes, err := ReplayWithRetries(context.Background(), url, payload, times, interval)
+	if err != nil {
+		log.Fatalln(err)
 	}
 
-	return b2cResponse, nil
+	log.Printf("[*] Replayed %s to %s: %v", path, url, statusCodes)
 }
 
 func main() {
+	replay := flag.String("replay", "", "path of a saved callback payload to post to -url instead of starting the server")
+	url := flag.String("url", "http://localhost:8080/stk-push-callback", "the endpoint a replayed callback is posted to")
+	times := flag.Int("times", 1, "how many times the replayed callback is delivered")
+	interval := flag.Duration("interval", time.Second, "the delay between the deliveries of a replayed callback")
+	flag.Parse()
+
+	if *replay != "" {
+		replayCallbackFile(*replay, *url, *times, *interval)
+		return
+	}
+
 	httpServer()
 }
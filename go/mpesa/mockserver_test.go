@@ -0,0 +1,109 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// mockResponse is a canned response of the mock server
+type mockResponse struct {
+	status int
+	body   string
+}
+
+// mockResponses are the responses the mock server starts with, those of a sandbox accepting every request
+var mockResponses = map[string]mockResponse{
+	oauthEndpoint.path: {http.StatusOK, `{"access_token":"mock-access-token","expires_in":"3599"}`},
+	stkPushEndpoint.path: {http.StatusOK, `{
+		"MerchantRequestID": "29115-34620561-1",
+		"CheckoutRequestID": "ws_CO_191220191020363925",
+		"ResponseCode": "0",
+		"ResponseDescription": "Success. Request accepted for processing",
+		"CustomerMessage": "Success. Request accepted for processing"
+	}`},
+	stkQueryEndpoint.path: {http.StatusOK, `{
+		"ResponseCode": "0",
+		"ResponseDescription": "The service request has been accepted successsfully",
+		"MerchantRequestID": "29115-34620561-1",
+		"CheckoutRequestID": "ws_CO_191220191020363925",
+		"ResultCode": "0",
+		"ResultDesc": "The service request is processed successfully."
+	}`},
+}
+
+// MockServer is a local stand in for the Safaricom APIs, for testing without credentials by pointing
+// MpesaOpts.BaseURL at its URL. It answers the oauth, STK push and STK push query endpoints with canned
+// responses that can be replaced with Respond.
+type MockServer struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responses map[string]mockResponse
+	failures  map[string]int
+	requests  map[string]int
+}
+
+// NewMockServer starts a mock server, which must be closed once it is no longer used
+func NewMockServer() *MockServer {
+	s := &MockServer{
+		responses: make(map[string]mockResponse, len(mockResponses)),
+		failures:  make(map[string]int),
+		requests:  make(map[string]int),
+	}
+
+	for path, resp := range mockResponses {
+		s.responses[path] = resp
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Respond makes the server answer the requests to path with the status and JSON body
+func (s *MockServer) Respond(path string, status int, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses[path] = mockResponse{status, body}
+}
+
+// FailNext makes the server answer the next n requests to path with 500, to exercise the retries
+func (s *MockServer) FailNext(path string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures[path] = n
+}
+
+// Requests returns how many requests were made to path
+func (s *MockServer) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[path]
+}
+
+func (s *MockServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests[r.URL.Path]++
+
+	failing := s.failures[r.URL.Path] > 0
+	if failing {
+		s.failures[r.URL.Path]--
+	}
+
+	resp, ok := s.responses[r.URL.Path]
+	s.mu.Unlock()
+
+	switch {
+	case failing:
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	case !ok:
+		http.NotFound(w, r)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(resp.status)
+		_, _ = w.Write([]byte(resp.body))
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in Kenyan shillings, kept in cents so that it is exact
+type Money int64
+
+// Shillings returns the Money for a whole number of shillings
+func Shillings(n int64) Money {
+	return Money(n * 100)
+}
+
+// String formats the amount with two decimal places, e.g. "10.50"
+func (m Money) String() string {
+	sign, cents := "", int64(m)
+	if cents < 0 {
+		sign, cents = "-", -cents
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+// ParseMoney parses an amount written in shillings with at most two decimal places, e.g. "-1540.00"
+func ParseMoney(s string) (Money, error) {
+	sign, digits := int64(1), strings.TrimSpace(s)
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = -1, digits[1:]
+	}
+
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole == "" || len(fraction) > 2 || strings.ContainsAny(whole+fraction, "+-") {
+		return 0, fmt.Errorf("mpesa: invalid amount %q", s)
+	}
+
+	shillings, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("mpesa: invalid amount %q", s)
+	}
+
+	var cents int64
+	if fraction != "" {
+		if cents, err = strconv.ParseInt(fraction, 10, 64); err != nil {
+			return 0, fmt.Errorf("mpesa: invalid amount %q", s)
+		}
+
+		if len(fraction) == 1 {
+			cents *= 10
+		}
+	}
+
+	return Money(sign * (shillings*100 + cents)), nil
+}
+
+// amountFormat is how an endpoint expects the Amount field to be written
+type amountFormat int
+
+const (
+	// wholeShillings amounts are integers, e.g. "10". Cents are rejected.
+	wholeShillings amountFormat = iota
+	// decimalShillings amounts have two decimal places, e.g. "10.50"
+	decimalShillings
+)
+
+// format writes the amount the way the format expects it
+func (f amountFormat) format(m Money) (string, error) {
+	if m <= 0 {
+		return "", &ValidationError{Field: "Amount", Reason: fmt.Sprintf("%s is not a positive amount", m)}
+	}
+
+	if f == decimalShillings {
+		return m.String(), nil
+	}
+
+	if m%100 != 0 {
+		return "", &ValidationError{Field: "Amount", Reason: fmt.Sprintf("%s is not a whole number of shillings", m)}
+	}
+
+	return strconv.FormatInt(int64(m/100), 10), nil
+}
+
+// normalize checks the amount written by the caller and rewrites it the way the format expects it, so that
+// "10.00" is sent as "10" to the endpoints taking whole shillings
+func (f amountFormat) normalize(raw string) (string, error) {
+	m, err := ParseMoney(raw)
+	if err != nil {
+		return "", &ValidationError{Field: "Amount", Reason: fmt.Sprintf("%q is not an amount in shillings", raw)}
+	}
+
+	return f.format(m)
+}
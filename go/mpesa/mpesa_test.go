@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestMpesa returns an app sending its requests to the mock server, with the shortcode of the STK pushes set
+func newTestMpesa(t *testing.T, s *MockServer, opts MpesaOpts) *Mpesa {
+	t.Helper()
+
+	opts.ConsumerKey = "mock-consumer-key"
+	opts.ConsumerSecret = "mock-consumer-secret"
+
+	if opts.BaseURL == "" {
+		opts.BaseURL = s.URL
+	}
+
+	if opts.Shortcode == "" {
+		opts.Shortcode = "174379"
+		opts.Passkey = "mock-passkey"
+		opts.CallbackURL = "https://example.com/mpesa/stk"
+	}
+
+	if opts.RetryBackoff == 0 {
+		opts.RetryBackoff = time.Millisecond
+	}
+
+	m, err := NewMpesa(&opts)
+	if err != nil {
+		t.Fatalf("NewMpesa() error = %v", err)
+	}
+
+	t.Cleanup(func() { _ = m.Close() })
+
+	return m
+}
+
+// testSTKPushBody returns an STK push body that passes validation once filled from the shortcode
+func testSTKPushBody() *STKPushRequestBody {
+	return &STKPushRequestBody{
+		TransactionType:  STKCustomerPayBillOnline,
+		Amount:           "10",
+		PartyA:           "254708374149",
+		PartyB:           "174379",
+		PhoneNumber:      "254708374149",
+		AccountReference: "order-1",
+		TransactionDesc:  "Payment",
+	}
+}
+
+func TestInitiateSTKPushRequest(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         MpesaOpts
+		setup        func(s *MockServer)
+		wantErrCode  string
+		wantRequests int
+		wantRetries  int64
+	}{
+		{
+			name:         "success",
+			wantRequests: 1,
+		},
+		{
+			name: "error envelope",
+			setup: func(s *MockServer) {
+				s.Respond(stkPushEndpoint.path, http.StatusBadRequest, `{
+					"requestId": "11728-2929992-1",
+					"errorCode": "400.002.02",
+					"errorMessage": "Bad Request - Invalid Amount"
+				}`)
+			},
+			wantErrCode:  "400.002.02",
+			wantRequests: 1,
+		},
+		{
+			name: "non-zero ResponseCode",
+			setup: func(s *MockServer) {
+				s.Respond(stkPushEndpoint.path, http.StatusOK, `{
+					"MerchantRequestID": "29115-34620561-1",
+					"CheckoutRequestID": "",
+					"ResponseCode": "1",
+					"ResponseDescription": "The balance is insufficient for the transaction"
+				}`)
+			},
+			wantErrCode:  "1",
+			wantRequests: 1,
+		},
+		{
+			name: "500 with retry",
+			opts: MpesaOpts{MaxRetries: 2, RetryNonIdempotent: true},
+			setup: func(s *MockServer) {
+				s.FailNext(stkPushEndpoint.path, 1)
+			},
+			wantRequests: 2,
+			wantRetries:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMockServer()
+			defer s.Close()
+
+			if tt.setup != nil {
+				tt.setup(s)
+			}
+
+			m := newTestMpesa(t, s, tt.opts)
+
+			resp, err := m.InitiateSTKPushRequest(testSTKPushBody())
+			if tt.wantErrCode != "" {
+				var mpesaErr *MpesaError
+				if !errors.As(err, &mpesaErr) || mpesaErr.ErrorCode != tt.wantErrCode {
+					t.Fatalf("InitiateSTKPushRequest() error = %v, want an *MpesaError with code %s", err, tt.wantErrCode)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("InitiateSTKPushRequest() error = %v", err)
+				}
+
+				if resp.CheckoutRequestID != "ws_CO_191220191020363925" {
+					t.Errorf("CheckoutRequestID = %q, want %q", resp.CheckoutRequestID, "ws_CO_191220191020363925")
+				}
+			}
+
+			if got := s.Requests(stkPushEndpoint.path); got != tt.wantRequests {
+				t.Errorf("STK push requests = %d, want %d", got, tt.wantRequests)
+			}
+
+			if got := m.Retries(); got != tt.wantRetries {
+				t.Errorf("Retries() = %d, want %d", got, tt.wantRetries)
+			}
+		})
+	}
+}
+
+func TestConcurrentSTKPushesShareOneToken(t *testing.T) {
+	s := NewMockServer()
+	defer s.Close()
+
+	m := newTestMpesa(t, s, MpesaOpts{})
+
+	const pushes = 50
+
+	var wg sync.WaitGroup
+	errs := make(chan error, pushes)
+
+	for i := 0; i < pushes; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if _, err := m.InitiateSTKPushRequest(testSTKPushBody()); err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if got := s.Requests(oauthEndpoint.path); got != 1 {
+		t.Errorf("oauth requests = %d, want 1", got)
+	}
+
+	if got := s.Requests(stkPushEndpoint.path); got != pushes {
+		t.Errorf("STK push requests = %d, want %d", got, pushes)
+	}
+}
+
+func TestBaseURLWithTrailingSlash(t *testing.T) {
+	s := NewMockServer()
+	defer s.Close()
+
+	m := newTestMpesa(t, s, MpesaOpts{BaseURL: s.URL + "/"})
+
+	if _, err := m.InitiateSTKPushRequest(testSTKPushBody()); err != nil {
+		t.Fatalf("InitiateSTKPushRequest() error = %v", err)
+	}
+
+	if got := s.Requests(oauthEndpoint.path); got != 1 {
+		t.Errorf("oauth requests = %d, want 1", got)
+	}
+
+	if got := s.Requests(stkPushEndpoint.path); got != 1 {
+		t.Errorf("STK push requests = %d, want 1", got)
+	}
+}
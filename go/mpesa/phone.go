@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultCountryCode is the country code of Kenya, used when MpesaOpts.DefaultCountryCode is not set
+const defaultCountryCode = "254"
+
+// msisdnLengths is the number of digits of a full MSISDN, country code included, for the known country codes
+var msisdnLengths = map[string]int{
+	"254": 12,
+}
+
+// NormalizePhoneNumber returns the Kenyan phone number in the 2547XXXXXXXX format expected by Safaricom. Numbers
+// written as 07XXXXXXXX, +2547XXXXXXXX or 7XXXXXXXX are accepted, and so are Airtel numbers starting with 2541.
+func NormalizePhoneNumber(raw string) (string, error) {
+	return normalizePhoneNumber("PhoneNumber", raw, defaultCountryCode)
+}
+
+// normalizePhoneNumber returns the phone number in the international format expected by Safaricom, e.g.
+// 2547XXXXXXXX. Local numbers like 07XXXXXXXX get the country code in place of the leading 0. Errors are
+// reported against the given request field.
+func normalizePhoneNumber(field, raw, countryCode string) (string, error) {
+	number := strings.NewReplacer(" ", "", "-", "", "(", "", ")", "").Replace(strings.TrimSpace(raw))
+	number = strings.TrimPrefix(number, "+")
+
+	if number == "" {
+		return "", &ValidationError{Field: field, Reason: "must not be empty"}
+	}
+
+	for _, r := range number {
+		if r < '0' || r > '9' {
+			return "", &ValidationError{Field: field, Reason: fmt.Sprintf("%q is not a phone number", raw)}
+		}
+	}
+
+	length, known := msisdnLengths[countryCode]
+
+	switch {
+	case strings.HasPrefix(number, "0"):
+		number = countryCode + number[1:]
+	case known && len(number) == length-len(countryCode):
+		number = countryCode + number
+	}
+
+	if !known {
+		// E.164 numbers are at most 15 digits long
+		if len(number) < 8 || len(number) > 15 {
+			return "", &ValidationError{Field: field, Reason: fmt.Sprintf("%q has an invalid length", raw)}
+		}
+
+		return number, nil
+	}
+
+	if !strings.HasPrefix(number, countryCode) || len(number) != length {
+		return "", &ValidationError{
+			Field:  field,
+			Reason: fmt.Sprintf("%q is not a %d digit number starting with %s", raw, length, countryCode),
+		}
+	}
+
+	return number, nil
+}
+
+// normalizePhoneNumber normalizes the phone number in the request field using the default country code of the app
+func (m *Mpesa) normalizePhoneNumber(field, raw string) (string, error) {
+	return normalizePhoneNumber(field, raw, m.countryCode)
+}
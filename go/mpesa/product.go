@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// Product is a Safaricom API posting results to a ResultURL
+type Product string
+
+const (
+	ProductUnknown           Product = ""
+	ProductB2C               Product = "b2c"
+	ProductB2B               Product = "b2b"
+	ProductReversal          Product = "reversal"
+	ProductTransactionStatus Product = "transaction_status"
+	ProductAccountBalance    Product = "account_balance"
+)
+
+// productResultKeys are the result parameters only found in the results of each product, checked in order
+var productResultKeys = []struct {
+	product Product
+	keys    []string
+}{
+	{ProductAccountBalance, []string{"AccountBalance"}},
+	{ProductReversal, []string{"OriginalTransactionID"}},
+	{ProductTransactionStatus, []string{"ReceiptNo", "TransactionStatus", "FinalisedTime"}},
+	{ProductB2B, []string{"InitiatorAccountCurrentBalance", "DebitPartyAffectedAccountBalance"}},
+	{ProductB2C, []string{"TransactionReceipt", "B2CRecipientIsRegisteredCustomer", "B2CUtilityAccountAvailableFunds"}},
+}
+
+// IdentifyResultProduct tells which product a result callback body belongs to from the result parameters it
+// carries. Failed results usually carry no parameters and cannot be identified, an error is returned for them.
+func IdentifyResultProduct(body []byte) (Product, error) {
+	var payload struct {
+		Result *ResultEnvelope `json:"Result"`
+	}
+
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return ProductUnknown, err
+	}
+
+	if payload.Result == nil {
+		return ProductUnknown, errors.New("mpesa: the body is not a result callback")
+	}
+
+	parameters := payload.Result.ResultParameters.ResultParameter
+
+	keys := make(map[string]bool, len(parameters))
+	for _, parameter := range parameters {
+		keys[parameter.Key] = true
+	}
+
+	for _, candidate := range productResultKeys {
+		for _, key := range candidate.keys {
+			if keys[key] {
+				return candidate.product, nil
+			}
+		}
+	}
+
+	return ProductUnknown, errors.New("mpesa: the result has no parameters identifying its product")
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RatibaFrequency is how often a standing order charges the customer
+type RatibaFrequency string
+
+const (
+	RatibaOneOff     RatibaFrequency = "1"
+	RatibaDaily      RatibaFrequency = "2"
+	RatibaWeekly     RatibaFrequency = "3"
+	RatibaMonthly    RatibaFrequency = "4"
+	RatibaBiMonthly  RatibaFrequency = "5"
+	RatibaQuarterly  RatibaFrequency = "6"
+	RatibaHalfYearly RatibaFrequency = "7"
+	RatibaYearly     RatibaFrequency = "8"
+)
+
+// ratibaDateLayout is the YYYYMMDD layout of the StartDate and EndDate of a standing order
+const ratibaDateLayout = "20060102"
+
+// RatibaRequestBody is the body of a request creating an M-Pesa Ratiba standing order, which charges the customer
+// at the given frequency between the start and end dates
+type RatibaRequestBody struct {
+	StandingOrderName string                `json:"StandingOrderName"` // Unique for each customer of the shortcode
+	StartDate         string                `json:"StartDate"`         // YYYYMMDD
+	EndDate           string                `json:"EndDate"`           // YYYYMMDD
+	BusinessShortCode string                `json:"BusinessShortCode"`
+	TransactionType   RatibaTransactionType `json:"TransactionType"`
+	// ReceiverPartyIdentifierType is "4" for a paybill and "2" for a till
+	ReceiverPartyIdentifierType string          `json:"ReceiverPartyIdentifierType"`
+	Amount                      string          `json:"Amount"` // Whole shillings, e.g. "10"
+	PartyA                      string          `json:"PartyA"` // The phone number charged
+	CallBackURL                 string          `json:"CallBackURL"`
+	AccountReference            string          `json:"AccountReference"`
+	TransactionDesc             string          `json:"TransactionDesc"`
+	Frequency                   RatibaFrequency `json:"Frequency"`
+}
+
+// RatibaResponseHeader is the header of the responses of the standing order API.
+//
+// Deprecated: use ResponseHeader, which is shared with the other APIs using the same envelope.
+type RatibaResponseHeader = ResponseHeader
+
+// RatibaResponse is the response sent back after creating a standing order
+type RatibaResponse struct {
+	ResponseHeader ResponseHeader `json:"ResponseHeader"`
+	ResponseBody   struct {
+		ResponseCode        string `json:"responseCode"`
+		ResponseDescription string `json:"responseDescription"`
+	} `json:"ResponseBody"`
+	RequestID    string `json:"requestId"`
+	ErrorCode    string `json:"errorCode"`
+	ErrorMessage string `json:"errorMessage"`
+}
+
+// validate checks the standing order request body before it is sent
+func (b *RatibaRequestBody) validate() error {
+	if err := validateCommand("TransactionType", b.TransactionType, RatibaCustomerPayBill, RatibaCustomerPayMerchant); err != nil {
+		return err
+	}
+
+	err := validateCommand(
+		"Frequency", b.Frequency, RatibaOneOff, RatibaDaily, RatibaWeekly, RatibaMonthly, RatibaBiMonthly,
+		RatibaQuarterly, RatibaHalfYearly, RatibaYearly,
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, date := range []struct{ field, value string }{{"StartDate", b.StartDate}, {"EndDate", b.EndDate}} {
+		if _, err := time.Parse(ratibaDateLayout, date.value); err != nil {
+			return &ValidationError{Field: date.field, Reason: fmt.Sprintf("%q is not a YYYYMMDD date", date.value)}
+		}
+	}
+
+	return nil
+}
+
+// CreateStandingOrder creates a standing order charging the customer in body.PartyA. The customer approves it on
+// their phone and the outcome is posted to the CallBackURL.
+func (m *Mpesa) CreateStandingOrder(body *RatibaRequestBody) (*RatibaResponse, error) {
+	return m.CreateStandingOrderWithContext(context.Background(), body)
+}
+
+// CreateStandingOrderWithContext is CreateStandingOrder with a context that cancels the request
+func (m *Mpesa) CreateStandingOrderWithContext(ctx context.Context, body *RatibaRequestBody) (*RatibaResponse, error) {
+	var err error
+
+	ratibaBody := *body
+	if ratibaBody.PartyA, err = m.normalizePhoneNumber("PartyA", ratibaBody.PartyA); err != nil {
+		return nil, err
+	}
+
+	if ratibaBody.Amount, err = ratibaEndpoint.amount.normalize(ratibaBody.Amount); err != nil {
+		return nil, err
+	}
+
+	if err := ratibaBody.validate(); err != nil {
+		return nil, err
+	}
+
+	ratibaResponse := new(RatibaResponse)
+	if _, err := m.send(ctx, ratibaEndpoint, ratibaBody, ratibaResponse); err != nil {
+		return nil, err
+	}
+
+	return ratibaResponse, nil
+}
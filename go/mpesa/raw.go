@@ -0,0 +1,19 @@
+package main
+
+import "context"
+
+type rawResponseContextKey struct{}
+
+// WithRawResponse returns a copy of ctx that makes the app store in raw the body Safaricom answered the last call
+// made with it, exactly as it was sent, so every API can keep the payload for audits and disputes. The body is
+// stored even when the call fails, as long as Safaricom answered.
+func WithRawResponse(ctx context.Context, raw *[]byte) context.Context {
+	return context.WithValue(ctx, rawResponseContextKey{}, raw)
+}
+
+// storeRawResponse stores body in the destination set on ctx with WithRawResponse, if any
+func storeRawResponse(ctx context.Context, body []byte) {
+	if raw, ok := ctx.Value(rawResponseContextKey{}).(*[]byte); ok && raw != nil {
+		*raw = body
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// mask returns maskedValue in place of a secret, or "" when the secret is not set
+func mask(secret string) string {
+	if secret == "" {
+		return ""
+	}
+
+	return maskedValue
+}
+
+// goSyntax replaces the name of the type in v, formatted with %#v, by name. The masked copies are formatted as
+// local types without the String methods, which would otherwise call themselves.
+func goSyntax(name, v string) string {
+	return name + v[strings.IndexByte(v, '{'):]
+}
+
+// String formats the body with the Password masked
+func (b STKPushRequestBody) String() string {
+	type plain STKPushRequestBody
+	b.Password = mask(b.Password)
+	return fmt.Sprintf("%+v", plain(b))
+}
+
+// GoString formats the body with the Password masked
+func (b STKPushRequestBody) GoString() string {
+	type plain STKPushRequestBody
+	b.Password = mask(b.Password)
+	return goSyntax("main.STKPushRequestBody", fmt.Sprintf("%#v", plain(b)))
+}
+
+// String formats the body with the SecurityCredential masked
+func (b B2CRequestBody) String() string {
+	type plain B2CRequestBody
+	b.SecurityCredential = mask(b.SecurityCredential)
+	return fmt.Sprintf("%+v", plain(b))
+}
+
+// GoString formats the body with the SecurityCredential masked
+func (b B2CRequestBody) GoString() string {
+	type plain B2CRequestBody
+	b.SecurityCredential = mask(b.SecurityCredential)
+	return goSyntax("main.B2CRequestBody", fmt.Sprintf("%#v", plain(b)))
+}
+
+// String formats the response with the AccessToken masked
+func (r MpesaAccessTokenResponse) String() string {
+	type plain MpesaAccessTokenResponse
+	r.AccessToken = mask(r.AccessToken)
+	return fmt.Sprintf("%+v", plain(r))
+}
+
+// GoString formats the response with the AccessToken masked
+func (r MpesaAccessTokenResponse) GoString() string {
+	type plain MpesaAccessTokenResponse
+	r.AccessToken = mask(r.AccessToken)
+	return goSyntax("main.MpesaAccessTokenResponse", fmt.Sprintf("%#v", plain(r)))
+}
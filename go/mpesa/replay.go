@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// ReplayCallback posts a saved callback payload to url the way Safaricom delivers it, so that handlers can be
+// debugged locally against real payloads. Sample payloads live in testdata/callbacks.
+func ReplayCallback(ctx context.Context, url string, payload []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	return http.DefaultClient.Do(req)
+}
+
+// ReplayWithRetries posts the payload to url the given number of times, waiting interval between deliveries, the
+// way Safaricom retries callbacks it considers undelivered. It returns the status code of every delivery so that
+// the acknowledgement and deduplication of repeated callbacks can be checked. times must be at least 1.
+func ReplayWithRetries(ctx context.Context, url string, payload []byte, times int, interval time.Duration) ([]int, error) {
+	if times < 1 {
+		return nil, fmt.Errorf("mpesa: the callback must be replayed at least once, not %d times", times)
+	}
+
+	statusCodes := make([]int, 0, times)
+
+	for i := 0; i < times; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return statusCodes, ctx.Err()
+			case <-time.After(interval):
+			}
+		}
+
+		resp, err := ReplayCallback(ctx, url, payload)
+		if err != nil {
+			return statusCodes, err
+		}
+
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		statusCodes = append(statusCodes, resp.StatusCode)
+	}
+
+	return statusCodes, nil
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReplayWithRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		times        int
+		wantErr      bool
+		wantRequests int64
+	}{
+		{name: "zero times", times: 0, wantErr: true},
+		{name: "negative times", times: -1, wantErr: true},
+		{name: "repeated delivery", times: 3, wantRequests: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int64
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+			}))
+			defer server.Close()
+
+			statusCodes, err := ReplayWithRetries(context.Background(), server.URL, []byte(`{}`), tt.times, 0)
+			if got := err != nil; got != tt.wantErr {
+				t.Fatalf("ReplayWithRetries() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("deliveries = %d, want %d", got, tt.wantRequests)
+			}
+
+			if int64(len(statusCodes)) != tt.wantRequests {
+				t.Errorf("ReplayWithRetries() = %v, want %d status codes", statusCodes, tt.wantRequests)
+			}
+		})
+	}
+}
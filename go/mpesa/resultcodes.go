@@ -0,0 +1,39 @@
+package main
+
+import "fmt"
+
+// ResponseCodeSuccess is the ResponseCode of a request Safaricom accepted for processing
+const ResponseCodeSuccess = "0"
+
+// The common ResultCode values of the STK push callbacks and the result callbacks
+const (
+	ResultCodeSuccess             = 0
+	ResultCodeInsufficientBalance = 1
+	ResultCodeSubscriberLocked    = 1001
+	ResultCodeTransactionExpired  = 1019
+	ResultCodePushFailed          = 1025
+	ResultCodeUserCancelled       = 1032
+	ResultCodeTimeout             = 1037
+	ResultCodeInvalidPIN          = 2001
+)
+
+// resultCodeDescriptions explain the common result codes in words that can be shown to customers
+var resultCodeDescriptions = map[int]string{
+	ResultCodeSuccess:             "The payment was completed",
+	ResultCodeInsufficientBalance: "The customer does not have enough funds",
+	ResultCodeSubscriberLocked:    "The customer has another transaction in progress",
+	ResultCodeTransactionExpired:  "The transaction expired before it was completed",
+	ResultCodePushFailed:          "The payment prompt could not be sent to the customer",
+	ResultCodeUserCancelled:       "The customer cancelled the payment",
+	ResultCodeTimeout:             "The customer could not be reached or did not answer in time",
+	ResultCodeInvalidPIN:          "The customer entered a wrong PIN",
+}
+
+// ResultCodeDescription returns a human readable explanation of the ResultCode
+func ResultCodeDescription(code int) string {
+	if description, ok := resultCodeDescriptions[code]; ok {
+		return description
+	}
+
+	return fmt.Sprintf("Unknown result code %d", code)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ResultEnvelope is the result Safaricom posts to the ResultURL of the asynchronous APIs: B2C, B2B, reversal,
+// transaction status and account balance. The product specific values are in the result parameters.
+type ResultEnvelope struct {
+	ResultType               int    `json:"ResultType"`
+	ResultCode               int    `json:"ResultCode"`
+	ResultDesc               string `json:"ResultDesc"`
+	OriginatorConversationID string `json:"OriginatorConversationID"`
+	ConversationID           string `json:"ConversationID"`
+	TransactionID            string `json:"TransactionID"`
+	ResultParameters         struct {
+		ResultParameter ResultItems `json:"ResultParameter"`
+	} `json:"ResultParameters"`
+	ReferenceData struct {
+		ReferenceItem ResultItems `json:"ReferenceItem"`
+	} `json:"ReferenceData"`
+}
+
+// ResultItem is one of the key value pairs of the result parameters and the reference data
+type ResultItem struct {
+	Key   string      `json:"Key"`
+	Value interface{} `json:"Value,omitempty"`
+}
+
+// ResultItems is a list of result items. Safaricom sends it as an array, or as a single object when there is
+// only one item, and both are decoded.
+type ResultItems []ResultItem
+
+func (items *ResultItems) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		*items = nil
+		return nil
+	case len(data) > 0 && data[0] == '{':
+		var item ResultItem
+		if err := json.Unmarshal(data, &item); err != nil {
+			return err
+		}
+
+		*items = ResultItems{item}
+		return nil
+	}
+
+	return json.Unmarshal(data, (*[]ResultItem)(items))
+}
+
+// find returns the value of the item with the given key
+func (items ResultItems) find(key string) (interface{}, bool) {
+	for _, item := range items {
+		if item.Key == key {
+			return item.Value, true
+		}
+	}
+
+	return nil, false
+}
+
+// Parameter returns the value of the result parameter with the given key
+func (r *ResultEnvelope) Parameter(key string) (interface{}, bool) {
+	return r.ResultParameters.ResultParameter.find(key)
+}
+
+// StringParameter returns the result parameter with the given key as a string. Numbers are formatted without
+// an exponent, since Safaricom sends some identifiers as numbers.
+func (r *ResultEnvelope) StringParameter(key string) (string, bool) {
+	value, ok := r.Parameter(key)
+	if !ok {
+		return "", false
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	}
+
+	return "", false
+}
+
+// NumberParameter returns the result parameter with the given key as a number. Numbers sent as strings, like
+// "190.00", are parsed.
+func (r *ResultEnvelope) NumberParameter(key string) (float64, bool) {
+	value, ok := r.Parameter(key)
+	if !ok {
+		return 0, false
+	}
+
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		return n, err == nil
+	}
+
+	return 0, false
+}
+
+// TimeParameter returns the result parameter with the given key as a time, for the YYYYMMDDHHmmss timestamps
+// such as "TransCompletedTime"
+func (r *ResultEnvelope) TimeParameter(key string) (time.Time, bool) {
+	value, ok := r.Parameter(key)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	t, err := ParseMpesaTimestamp(value)
+	return t, err == nil
+}
+
+// Reference returns the value of the reference item with the given key, such as "QueueTimeoutURL"
+func (r *ResultEnvelope) Reference(key string) (string, bool) {
+	value, ok := r.ReferenceData.ReferenceItem.find(key)
+	if !ok {
+		return "", false
+	}
+
+	s, ok := value.(string)
+	return s, ok
+}
+
+// ResultOutcome is the final state of a transaction reported in a result callback
+type ResultOutcome int
+
+const (
+	ResultUnknown ResultOutcome = iota
+	ResultSuccess
+	ResultFailed
+	ResultTimeout
+)
+
+func (o ResultOutcome) String() string {
+	switch o {
+	case ResultSuccess:
+		return "success"
+	case ResultFailed:
+		return "failed"
+	case ResultTimeout:
+		return "timeout"
+	}
+
+	return "unknown"
+}
+
+// timeoutResultCodes are the result codes reported when the transaction was not completed in time
+var timeoutResultCodes = map[int]bool{
+	ResultCodeTransactionExpired: true,
+	ResultCodeTimeout:            true,
+}
+
+// Outcome classifies the result as a success, a failure or a timeout. An error is returned when the ResultType
+// is not one Safaricom documents, since the ResultCode cannot be trusted then.
+func (r *ResultEnvelope) Outcome() (ResultOutcome, error) {
+	if r.ResultType != 0 {
+		return ResultUnknown, fmt.Errorf("mpesa: unknown ResultType %d with ResultCode %d", r.ResultType, r.ResultCode)
+	}
+
+	switch {
+	case r.ResultCode == ResultCodeSuccess:
+		return ResultSuccess, nil
+	case timeoutResultCodes[r.ResultCode]:
+		return ResultTimeout, nil
+	}
+
+	return ResultFailed, nil
+}
+
+// timeoutResultDesc is the ResultDesc of the requests that timed out in Safaricom's queue
+const timeoutResultDesc = "The service request has timed out."
+
+// TimeoutCallback returns the body Safaricom posts to the QueueTimeOutURL of a B2C or account balance request
+// that timed out in its queue, which the sandbox rarely does. Post it to the timeout handlers to exercise them,
+// it decodes into a B2CTimeoutCallback or any other struct with a ResultEnvelope.
+func TimeoutCallback(originatorConversationID, conversationID, queueTimeOutURL string) []byte {
+	type referenceData struct {
+		ReferenceItem ResultItem `json:"ReferenceItem"`
+	}
+
+	type result struct {
+		ResultType               int           `json:"ResultType"`
+		ResultCode               int           `json:"ResultCode"`
+		ResultDesc               string        `json:"ResultDesc"`
+		OriginatorConversationID string        `json:"OriginatorConversationID"`
+		ConversationID           string        `json:"ConversationID"`
+		TransactionID            string        `json:"TransactionID"`
+		ReferenceData            referenceData `json:"ReferenceData"`
+	}
+
+	body, _ := json.Marshal(struct {
+		Result result `json:"Result"`
+	}{result{
+		ResultCode:               ResultCodeTimeout,
+		ResultDesc:               timeoutResultDesc,
+		OriginatorConversationID: originatorConversationID,
+		ConversationID:           conversationID,
+		TransactionID:            "0000000000000",
+		ReferenceData:            referenceData{ResultItem{Key: "QueueTimeoutURL", Value: queueTimeOutURL}},
+	}})
+
+	return body
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTimeoutCallbacksHaveTheTimeoutOutcome(t *testing.T) {
+	tests := []struct {
+		name string
+		body []byte
+		// result decodes the body into the callback of the product and returns its envelope
+		result func(body []byte) (*ResultEnvelope, error)
+	}{
+		{
+			name: "TimeoutCallback",
+			body: TimeoutCallback("16917-22577599-3", "AG_20200206_00005e091a8ec6b9eac5", "https://example.com/b2c/timeout"),
+			result: func(body []byte) (*ResultEnvelope, error) {
+				callback := new(B2CTimeoutCallback)
+				return &callback.Result, json.Unmarshal(body, callback)
+			},
+		},
+		{
+			name: "b2c_timeout.json",
+			body: []byte(readCallback(t, "b2c_timeout.json")),
+			result: func(body []byte) (*ResultEnvelope, error) {
+				callback := new(B2CTimeoutCallback)
+				return &callback.Result, json.Unmarshal(body, callback)
+			},
+		},
+		{
+			name: "account_balance_timeout.json",
+			body: []byte(readCallback(t, "account_balance_timeout.json")),
+			result: func(body []byte) (*ResultEnvelope, error) {
+				callback := new(AccountBalanceResultCallback)
+				return &callback.Result, json.Unmarshal(body, callback)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.result(tt.body)
+			if err != nil {
+				t.Fatalf("decoding the callback: %v", err)
+			}
+
+			outcome, err := result.Outcome()
+			if err != nil || outcome != ResultTimeout {
+				t.Errorf("Outcome() = %v, %v, want ResultTimeout", outcome, err)
+			}
+
+			if result.OriginatorConversationID == "" || result.ConversationID == "" {
+				t.Errorf("the callback has no conversation IDs: %+v", result)
+			}
+		})
+	}
+}
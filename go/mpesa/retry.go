@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// defaultRetryBackoff is the delay before the first retry when MpesaOpts.RetryBackoff is not set
+const defaultRetryBackoff = 500 * time.Millisecond
+
+// maxRetryBackoff is the longest delay between two retries, however many retries came before
+const maxRetryBackoff = 30 * time.Second
+
+// maxAttempts returns how many times a request to the endpoint may be sent
+func (m *Mpesa) maxAttempts(e endpoint) int {
+	if !e.idempotent && !m.retryNonIdempotent {
+		return 1
+	}
+
+	return 1 + m.maxRetries
+}
+
+// retryDelay returns the exponential backoff of the given retry, capped at maxRetryBackoff
+func (m *Mpesa) retryDelay(retry int) time.Duration {
+	delay := m.retryBackoff
+	if delay <= 0 {
+		delay = defaultRetryBackoff
+	}
+
+	for i := 1; i < retry && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+
+	if delay > maxRetryBackoff {
+		return maxRetryBackoff
+	}
+
+	return delay
+}
+
+// waitBeforeRetry sleeps for the exponential backoff of the given retry, returning early when ctx is done
+func (m *Mpesa) waitBeforeRetry(ctx context.Context, retry int) error {
+	timer := time.NewTimer(m.retryDelay(retry))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// waitForRateLimit waits for the turn of the next request under MpesaOpts.RateLimit. Requests that could not be
+// sent before the deadline of ctx fail right away with an error wrapping context.DeadlineExceeded.
+func (m *Mpesa) waitForRateLimit(ctx context.Context) error {
+	if m.limiter == nil {
+		return nil
+	}
+
+	if err := m.limiter.Wait(ctx); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		return fmt.Errorf("mpesa: the rate limit delays the request past its deadline: %w", context.DeadlineExceeded)
+	}
+
+	return nil
+}
+
+// Retries returns how many times requests were sent again after a transient failure since the app was created
+func (m *Mpesa) Retries() int64 {
+	return m.retries.Load()
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryOfNonJSONResponses(t *testing.T) {
+	tests := []struct {
+		name            string
+		status          int
+		wantRequests    int
+		wantUnavailable bool
+	}{
+		{name: "maintenance page with 200", status: http.StatusOK, wantRequests: 4, wantUnavailable: true},
+		{name: "gateway page with 502", status: http.StatusBadGateway, wantRequests: 4, wantUnavailable: true},
+		{name: "not found page", status: http.StatusNotFound, wantRequests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMockServer()
+			defer s.Close()
+
+			s.Respond(stkQueryEndpoint.path, tt.status, "<html><body>Not available</body></html>")
+			m := newTestMpesa(t, s, MpesaOpts{MaxRetries: 3})
+
+			_, err := m.QuerySTKPushStatus(&STKPushQueryRequestBody{CheckoutRequestID: "ws_CO_191220191020363925"})
+
+			var httpErr *HTTPError
+			if !errors.As(err, &httpErr) || httpErr.StatusCode != tt.status {
+				t.Fatalf("QuerySTKPushStatus() error = %v, want an *HTTPError with status %d", err, tt.status)
+			}
+
+			if got := errors.Is(err, ErrServiceUnavailable); got != tt.wantUnavailable {
+				t.Errorf("errors.Is(err, ErrServiceUnavailable) = %v, want %v", got, tt.wantUnavailable)
+			}
+
+			if got := s.Requests(stkQueryEndpoint.path); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestSTKPushRetryNeedsRetryNonIdempotent(t *testing.T) {
+	tests := []struct {
+		name               string
+		retryNonIdempotent bool
+		wantRequests       int
+		wantErr            bool
+	}{
+		{name: "not retried by default", wantRequests: 1, wantErr: true},
+		{name: "retried with RetryNonIdempotent", retryNonIdempotent: true, wantRequests: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMockServer()
+			defer s.Close()
+
+			s.FailNext(stkPushEndpoint.path, 1)
+			m := newTestMpesa(t, s, MpesaOpts{MaxRetries: 3, RetryNonIdempotent: tt.retryNonIdempotent})
+
+			_, err := m.InitiateSTKPushRequest(testSTKPushBody())
+			if tt.wantErr && !errors.Is(err, ErrServiceUnavailable) {
+				t.Fatalf("InitiateSTKPushRequest() error = %v, want ErrServiceUnavailable", err)
+			}
+
+			if !tt.wantErr && err != nil {
+				t.Fatalf("InitiateSTKPushRequest() error = %v", err)
+			}
+
+			if got := s.Requests(stkPushEndpoint.path); got != tt.wantRequests {
+				t.Errorf("STK push requests = %d, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestRetryOfServerAndClientErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		setup        func(s *MockServer)
+		wantErr      bool
+		wantRequests int
+		wantRetries  int64
+	}{
+		{
+			name:         "5xx retried",
+			setup:        func(s *MockServer) { s.FailNext(stkQueryEndpoint.path, 2) },
+			wantRequests: 3,
+			wantRetries:  2,
+		},
+		{
+			name: "4xx not retried",
+			setup: func(s *MockServer) {
+				s.Respond(stkQueryEndpoint.path, http.StatusBadRequest, `{
+					"requestId": "11728-2929992-1",
+					"errorCode": "400.002.02",
+					"errorMessage": "Bad Request - Invalid CheckoutRequestID"
+				}`)
+			},
+			wantErr:      true,
+			wantRequests: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMockServer()
+			defer s.Close()
+
+			tt.setup(s)
+			m := newTestMpesa(t, s, MpesaOpts{MaxRetries: 3})
+
+			_, err := m.QuerySTKPushStatus(&STKPushQueryRequestBody{CheckoutRequestID: "ws_CO_191220191020363925"})
+			if got := err != nil; got != tt.wantErr {
+				t.Fatalf("QuerySTKPushStatus() error = %v, want error %v", err, tt.wantErr)
+			}
+
+			if got := s.Requests(stkQueryEndpoint.path); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+
+			if got := m.Retries(); got != tt.wantRetries {
+				t.Errorf("Retries() = %d, want %d", got, tt.wantRetries)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		backoff time.Duration
+		retry   int
+		want    time.Duration
+	}{
+		{name: "first retry", retry: 1, want: defaultRetryBackoff},
+		{name: "doubled", backoff: time.Second, retry: 3, want: 4 * time.Second},
+		{name: "capped", backoff: time.Second, retry: 10, want: maxRetryBackoff},
+		{name: "no overflow", backoff: time.Second, retry: 100, want: maxRetryBackoff},
+		{name: "backoff above the cap", backoff: time.Minute, retry: 1, want: maxRetryBackoff},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Mpesa{retryBackoff: tt.backoff}
+
+			if got := m.retryDelay(tt.retry); got != tt.want {
+				t.Errorf("retryDelay(%d) = %v, want %v", tt.retry, got, tt.want)
+			}
+		})
+	}
+}
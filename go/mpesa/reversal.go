@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+// ReversalRequestBody is the body of a request reversing a transaction
+type ReversalRequestBody struct {
+	Initiator          string          `json:"Initiator"`
+	SecurityCredential string          `json:"SecurityCredential"`
+	CommandID          ReversalCommand `json:"CommandID"` // Defaults to ReversalTransactionReversal
+	TransactionID      string          `json:"TransactionID"`
+	Amount             string          `json:"Amount"`
+	ReceiverParty      string          `json:"ReceiverParty"`
+	// RecieverIdentifierType is spelled the way Safaricom expects it, "11" for an organization
+	RecieverIdentifierType string `json:"RecieverIdentifierType"`
+	ResultURL              string `json:"ResultURL"`
+	QueueTimeOutURL        string `json:"QueueTimeOutURL"`
+	Remarks                string `json:"Remarks"`
+	Occasion               string `json:"Occasion,omitempty"`
+}
+
+// validate checks the reversal request body before it is sent
+func (b *ReversalRequestBody) validate(logger *slog.Logger) error {
+	if err := validateCommand("CommandID", b.CommandID, ReversalTransactionReversal); err != nil {
+		return err
+	}
+
+	if b.TransactionID == "" {
+		return &ValidationError{Field: "TransactionID", Reason: "must not be empty"}
+	}
+
+	return validateResultURLs(b.ResultURL, b.QueueTimeOutURL, logger)
+}
+
+// InitiateReversal requests the reversal of the transaction in body.TransactionID. The outcome is posted to the
+// ResultURL and decoded with ReversalResultCallback. The initiator and security credential configured on the
+// app are used when the body leaves them empty.
+func (m *Mpesa) InitiateReversal(body *ReversalRequestBody) (*AcknowledgementResponse, error) {
+	return m.InitiateReversalWithContext(context.Background(), body)
+}
+
+// InitiateReversalWithContext is InitiateReversal with a context that cancels the request
+func (m *Mpesa) InitiateReversalWithContext(ctx context.Context, body *ReversalRequestBody) (*AcknowledgementResponse, error) {
+	reversalBody := *body
+	if reversalBody.CommandID == "" {
+		reversalBody.CommandID = ReversalTransactionReversal
+	}
+
+	var err error
+	if reversalBody.Amount, err = reversalEndpoint.amount.normalize(reversalBody.Amount); err != nil {
+		return nil, err
+	}
+
+	if err := m.fillInitiator(&reversalBody.Initiator, &reversalBody.SecurityCredential); err != nil {
+		return nil, err
+	}
+
+	if err := reversalBody.validate(m.logger); err != nil {
+		return nil, err
+	}
+
+	ack := new(AcknowledgementResponse)
+	if _, err := m.send(ctx, reversalEndpoint, reversalBody, ack); err != nil {
+		return nil, err
+	}
+
+	return ack, nil
+}
+
+// ReversalResultCallback is the outcome of a reversal posted to the ResultURL
+type ReversalResultCallback struct {
+	Result ResultEnvelope `json:"Result"`
+}
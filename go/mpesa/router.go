@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// CallbackRouter is an http.Handler dispatching the callbacks and results Safaricom posts to the functions
+// registered for their path. Each body is decoded into the struct of the product registered on the path, bodies
+// that cannot be decoded are answered with 400, those over 1MB with 413, and they never reach the functions.
+// Callbacks are acknowledged with {"ResultCode":0,"ResultDesc":"Accepted"} once processed, otherwise Safaricom
+// keeps delivering them.
+type CallbackRouter struct {
+	mux                  *http.ServeMux
+	decodeError          func(*http.Request, error)
+	billRefNumberPattern *regexp.Regexp
+}
+
+// NewCallbackRouter returns a router without any registered callbacks
+func NewCallbackRouter() *CallbackRouter {
+	return &CallbackRouter{mux: http.NewServeMux()}
+}
+
+// NewCallbackRouter returns a router without any registered callbacks, validating the C2B account numbers
+// against MpesaOpts.BillRefNumberPattern
+func (m *Mpesa) NewCallbackRouter() *CallbackRouter {
+	r := NewCallbackRouter()
+	r.billRefNumberPattern = m.billRefNumberPattern
+
+	return r
+}
+
+func (r *CallbackRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mux.ServeHTTP(w, req)
+}
+
+// OnDecodeError calls fn with the requests whose body could not be decoded, before they are answered with 400
+func (r *CallbackRouter) OnDecodeError(fn func(*http.Request, error)) {
+	r.decodeError = fn
+}
+
+// OnSTKCallback calls fn with the STK push callbacks posted to path, the path of the CallBackURL
+func (r *CallbackRouter) OnSTKCallback(path string, fn func(*STKPushCallbackResponse)) {
+	r.mux.Handle(path, callbackHandler(r, fn))
+}
+
+// OnSTKCallbackChan sends the STK push callbacks posted to path to ch, for workers processing them outside the
+// handler. When ch is full the handler waits up to timeout for room, 0 not waiting at all, and a callback that
+// still does not fit is dropped and answered with 503. The callbacks of a batch delivered before one was dropped
+// are not taken back, so workers should ignore the CheckoutRequestIDs they already processed.
+func (r *CallbackRouter) OnSTKCallbackChan(path string, ch chan<- *STKPushCallbackResponse, timeout time.Duration) {
+	r.mux.Handle(path, replyingHandler(r, func(payload *STKPushCallbackResponse) interface{} {
+		if !deliver(ch, payload, timeout) {
+			return droppedCallback
+		}
+
+		return nil
+	}))
+}
+
+// OnSTKCallbackAwaiter hands the STK push callbacks posted to path to the AwaitSTKResult calls waiting for them on
+// a. The callbacks nobody waits for are acknowledged all the same.
+func (r *CallbackRouter) OnSTKCallbackAwaiter(path string, a *STKAwaiter) {
+	r.mux.Handle(path, callbackHandler(r, func(payload *STKPushCallbackResponse) {
+		a.Deliver(payload)
+	}))
+}
+
+// OnC2BConfirmation calls fn with the C2B payments posted to path, the path of the ConfirmationURL
+func (r *CallbackRouter) OnC2BConfirmation(path string, fn func(*C2BCallback)) {
+	r.mux.Handle(path, callbackHandler(r, fn))
+}
+
+// OnC2BValidation calls fn with the C2B payments posted to path, the path of the ValidationURL, and answers
+// Safaricom with the validation response it returns. Returning nil accepts the payment. The payments failing
+// ValidateBillRefNumber, with the pattern of the Mpesa the router was created from, are rejected with
+// C2BRejectInvalidAccountNumber without calling fn.
+func (r *CallbackRouter) OnC2BValidation(path string, fn func(*C2BCallback) *C2BValidationResponse) {
+	r.mux.Handle(path, replyingHandler(r, func(payload *C2BCallback) interface{} {
+		if command := payload.command(); command != "" &&
+			ValidateBillRefNumber(command, payload.BillRefNumber, r.billRefNumberPattern) != nil {
+			return RejectC2BPayment(C2BRejectInvalidAccountNumber)
+		}
+
+		if reply := fn(payload); reply != nil {
+			return reply
+		}
+
+		return nil
+	}))
+}
+
+// OnB2CResult calls fn with the B2C results posted to path, the path of the ResultURL
+func (r *CallbackRouter) OnB2CResult(path string, fn func(*B2CResultCallback)) {
+	r.mux.Handle(path, callbackHandler(r, fn))
+}
+
+// OnB2CTimeout calls fn with the B2C timeouts posted to path, the path of the QueueTimeOutURL
+func (r *CallbackRouter) OnB2CTimeout(path string, fn func(*B2CTimeoutCallback)) {
+	r.mux.Handle(path, callbackHandler(r, fn))
+}
+
+// OnAccountBalanceResult calls fn with the account balance results posted to path, the path of the ResultURL
+func (r *CallbackRouter) OnAccountBalanceResult(path string, fn func(*AccountBalanceResultCallback)) {
+	r.mux.Handle(path, callbackHandler(r, fn))
+}
+
+// OnTransactionStatusResult calls fn with the transaction status results posted to path, the path of the ResultURL
+func (r *CallbackRouter) OnTransactionStatusResult(path string, fn func(*TransactionStatusResultCallback)) {
+	r.mux.Handle(path, callbackHandler(r, fn))
+}
+
+// OnReversalResult calls fn with the reversal results posted to path, the path of the ResultURL
+func (r *CallbackRouter) OnReversalResult(path string, fn func(*ReversalResultCallback)) {
+	r.mux.Handle(path, callbackHandler(r, fn))
+}
+
+// maxCallbackBodySize is the largest callback body the router reads, batches included. Larger bodies are answered
+// with 413 without being decoded.
+const maxCallbackBodySize = 1 << 20
+
+// callbackAck is the body acknowledging a callback
+type callbackAck struct {
+	ResultCode int    `json:"ResultCode"`
+	ResultDesc string `json:"ResultDesc"`
+}
+
+// acceptedCallback acknowledges the callbacks that were processed
+var acceptedCallback = &callbackAck{ResultCode: ResultCodeSuccess, ResultDesc: "Accepted"}
+
+// callbackRejection is the reply of the callbacks that were not processed, answered with the status instead of
+// an acknowledgement
+type callbackRejection struct {
+	status  int
+	message string
+}
+
+// droppedCallback rejects the callbacks dropped because the channel they are delivered to is full
+var droppedCallback = &callbackRejection{status: http.StatusServiceUnavailable, message: "callback dropped"}
+
+// deliver sends v to ch, waiting up to timeout when it is full. It reports whether v was sent.
+func deliver[T any](ch chan<- *T, v *T, timeout time.Duration) bool {
+	select {
+	case ch <- v:
+		return true
+	default:
+	}
+
+	if timeout <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case ch <- v:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// callbackHandler decodes the callbacks in the body of POST requests, calls fn with each of them and
+// acknowledges them
+func callbackHandler[T any](r *CallbackRouter, fn func(*T)) http.Handler {
+	return replyingHandler(r, func(payload *T) interface{} {
+		fn(payload)
+		return nil
+	})
+}
+
+// replyingHandler decodes the callbacks in the body of POST requests and calls fn with each of them. The first
+// reply fn returns is sent back, the callbacks are acknowledged when it returns none.
+func replyingHandler[T any](r *CallbackRouter, fn func(*T) interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		payloads, err := decodeCallbacks[T](http.MaxBytesReader(w, req.Body, maxCallbackBodySize))
+		if err != nil {
+			if r.decodeError != nil {
+				r.decodeError(req, err)
+			}
+
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				http.Error(w, "callback too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			http.Error(w, "malformed callback", http.StatusBadRequest)
+			return
+		}
+
+		var reply interface{}
+		for _, payload := range payloads {
+			if payloadReply := fn(payload); payloadReply != nil && reply == nil {
+				reply = payloadReply
+			}
+		}
+
+		if rejection, ok := reply.(*callbackRejection); ok {
+			http.Error(w, rejection.message, rejection.status)
+			return
+		}
+
+		if reply == nil {
+			reply = acceptedCallback
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(reply)
+	})
+}
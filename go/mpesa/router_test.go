@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// postCallback posts body to the path of the router and returns the recorded response
+func postCallback(r *CallbackRouter, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+
+	return rec
+}
+
+// readCallback returns the content of the callback in testdata/callbacks
+func readCallback(t *testing.T, name string) string {
+	t.Helper()
+
+	body, err := os.ReadFile("testdata/callbacks/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(body)
+}
+
+func TestSTKCallbackBatch(t *testing.T) {
+	r := NewCallbackRouter()
+
+	delivered := make(map[int]int)
+	r.OnSTKCallback("/stk", func(callback *STKPushCallbackResponse) {
+		delivered[callback.Body.StkCallback.ResultCode]++
+	})
+
+	batch := "[" + readCallback(t, "stk_success.json") + "," + readCallback(t, "stk_cancelled.json") + "]"
+	rec := postCallback(r, "/stk", batch)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	if delivered[ResultCodeSuccess] != 1 || delivered[1032] != 1 || len(delivered) != 2 {
+		t.Errorf("delivered = %v, want each callback of the batch once", delivered)
+	}
+
+	decoder := json.NewDecoder(rec.Body)
+
+	var ack callbackAck
+	if err := decoder.Decode(&ack); err != nil || ack != *acceptedCallback {
+		t.Fatalf("ack = %+v (%v), want %+v", ack, err, *acceptedCallback)
+	}
+
+	if decoder.More() {
+		t.Error("the batch was acknowledged more than once")
+	}
+}
+
+func TestSTKCallbackBatchWithNull(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "only null", body: `[null]`},
+		{name: "null after a callback", body: `[{"Body":{"stkCallback":{"CheckoutRequestID":"ws_CO_1"}}}, null]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewCallbackRouter()
+
+			calls := 0
+			r.OnSTKCallback("/stk", func(*STKPushCallbackResponse) { calls++ })
+
+			if rec := postCallback(r, "/stk", tt.body); rec.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+			}
+
+			if calls != 0 {
+				t.Errorf("the handler was called %d times, want 0", calls)
+			}
+		})
+	}
+}
+
+func TestCallbackBodyTooLarge(t *testing.T) {
+	r := NewCallbackRouter()
+
+	calls := 0
+	r.OnSTKCallback("/stk", func(*STKPushCallbackResponse) { calls++ })
+
+	padding := strings.Repeat(" ", maxCallbackBodySize)
+	rec := postCallback(r, "/stk", "["+padding+readCallback(t, "stk_success.json")+"]")
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+
+	if calls != 0 {
+		t.Errorf("the handler was called %d times, want 0", calls)
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNoShortcode is returned by STK and Pay when no shortcode has been registered with UseShortcode or MpesaOpts
+var ErrNoShortcode = errors.New("mpesa: no shortcode registered, set MpesaOpts.Shortcode or call UseShortcode first")
+
+// ShortcodeConfig holds everything needed to charge customers into a paybill or till, so that STK push requests
+// only need the amount and the phone number.
+type ShortcodeConfig struct {
+	// Name identifies the config when the app registers more than one shortcode
+	Name             string
+	Shortcode        string
+	Passkey          string
+	TransactionType  STKTransactionType // Defaults to STKCustomerPayBillOnline
+	PartyB           string             // The till number for buy goods, defaults to Shortcode for paybills
+	CallbackURL      string
+	AccountReference string
+	TransactionDesc  string
+}
+
+// stkPushRequestBody builds the STK push request body charging amount from phone at the given time
+func (c *ShortcodeConfig) stkPushRequestBody(amount int, phone string, now time.Time) (*STKPushRequestBody, error) {
+	formattedAmount, err := stkPushEndpoint.amount.format(Shillings(int64(amount)))
+	if err != nil {
+		return nil, err
+	}
+
+	password, timestamp := stkPushPassword(c.Shortcode, c.Passkey, now)
+
+	transactionType := c.TransactionType
+	if transactionType == "" {
+		transactionType = STKCustomerPayBillOnline
+	}
+
+	partyB := c.PartyB
+	if partyB == "" && transactionType == STKCustomerPayBillOnline {
+		partyB = c.Shortcode
+	}
+
+	return &STKPushRequestBody{
+		BusinessShortCode: c.Shortcode,
+		Password:          password,
+		Timestamp:         timestamp,
+		TransactionType:   transactionType,
+		Amount:            formattedAmount,
+		PartyA:            phone,
+		PartyB:            partyB,
+		PhoneNumber:       phone,
+		CallBackURL:       c.CallbackURL,
+		AccountReference:  c.AccountReference,
+		TransactionDesc:   c.TransactionDesc,
+	}, nil
+}
+
+// UseShortcode registers cfg under its name and makes it the shortcode used by STK. Registering a config with a
+// name that is already taken replaces it.
+func (m *Mpesa) UseShortcode(cfg *ShortcodeConfig) {
+	c := *cfg
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.shortcodes == nil {
+		m.shortcodes = make(map[string]*ShortcodeConfig)
+	}
+
+	m.shortcodes[c.Name] = &c
+	m.defaultShortcode = c.Name
+}
+
+// shortcode returns the registered config with the given name
+func (m *Mpesa) shortcode(name string) (*ShortcodeConfig, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	cfg, ok := m.shortcodes[name]
+	if !ok {
+		return nil, fmt.Errorf("mpesa: shortcode %q is not registered", name)
+	}
+
+	return cfg, nil
+}
+
+// defaultShortcodeConfig returns the config last passed to UseShortcode
+func (m *Mpesa) defaultShortcodeConfig() (*ShortcodeConfig, error) {
+	m.mu.RLock()
+	name, ok := m.defaultShortcode, m.shortcodes != nil
+	m.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrNoShortcode
+	}
+
+	return m.shortcode(name)
+}
+
+// STK initiates an STK push request charging amount from phone into the shortcode last passed to UseShortcode
+func (m *Mpesa) STK(amount int, phone string) (*STKPushRequestResponse, error) {
+	return m.STKWithContext(context.Background(), amount, phone)
+}
+
+// STKWithContext is STK with a context that cancels the request
+func (m *Mpesa) STKWithContext(ctx context.Context, amount int, phone string) (*STKPushRequestResponse, error) {
+	cfg, err := m.defaultShortcodeConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return m.stkPush(ctx, cfg, amount, phone)
+}
+
+// STKFor initiates an STK push request charging amount from phone into the shortcode registered under name
+func (m *Mpesa) STKFor(name string, amount int, phone string) (*STKPushRequestResponse, error) {
+	return m.STKForWithContext(context.Background(), name, amount, phone)
+}
+
+// STKForWithContext is STKFor with a context that cancels the request
+func (m *Mpesa) STKForWithContext(ctx context.Context, name string, amount int, phone string) (*STKPushRequestResponse, error) {
+	cfg, err := m.shortcode(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.stkPush(ctx, cfg, amount, phone)
+}
+
+// stkPush initiates an STK push request charging amount from phone into the shortcode of cfg
+func (m *Mpesa) stkPush(ctx context.Context, cfg *ShortcodeConfig, amount int, phone string) (*STKPushRequestResponse, error) {
+	phone, err := m.normalizePhoneNumber("PhoneNumber", phone)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := cfg.stkPushRequestBody(amount, phone, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return m.InitiateSTKPushRequestWithContext(ctx, body)
+}
+
+// Pay initiates an STK push request charging amount from phone into the shortcode last passed to UseShortcode, or
+// the one set in MpesaOpts, with the given account reference and description
+func (m *Mpesa) Pay(ctx context.Context, phone string, amount int, accountRef, description string) (*STKPushRequestResponse, error) {
+	cfg, err := m.defaultShortcodeConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	c := *cfg
+	c.AccountReference, c.TransactionDesc = accountRef, description
+
+	return m.stkPush(ctx, &c, amount, phone)
+}
+
+// fillSTKPush sets the shortcode, passkey and callback URL configured on the app on the STK push fields that
+// were left empty. The password is only generated when both it and the timestamp are empty, since they are sent
+// together, and for the configured shortcode, since the passkey belongs to it.
+func (m *Mpesa) fillSTKPush(body *STKPushRequestBody, now time.Time) {
+	if body.BusinessShortCode == "" {
+		body.BusinessShortCode = m.businessShortCode
+	}
+
+	if body.Password == "" && body.Timestamp == "" && m.passkey != "" && body.BusinessShortCode == m.businessShortCode {
+		body.Password, body.Timestamp = stkPushPassword(body.BusinessShortCode, m.passkey, now)
+	}
+
+	if body.CallBackURL == "" {
+		body.CallBackURL = m.callbackURL
+	}
+}
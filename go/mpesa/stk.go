@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/base64"
+	"strconv"
+	"time"
+)
+
+// GenerateSTKPushPassword returns the Password and Timestamp fields of an STK push or STK push query request. The
+// password is the base64 encoding of the shortcode, the passkey and the timestamp it is returned with, so both
+// must be sent together.
+func GenerateSTKPushPassword(shortcode, passkey string) (password, timestamp string) {
+	return stkPushPassword(shortcode, passkey, time.Now())
+}
+
+// stkPushPassword returns the STK push password and timestamp for the given time
+func stkPushPassword(shortcode, passkey string, now time.Time) (password, timestamp string) {
+	timestamp = now.Format(mpesaTimestampLayout)
+	password = base64.StdEncoding.EncodeToString([]byte(shortcode + passkey + timestamp))
+
+	return password, timestamp
+}
+
+// Accepted reports whether Safaricom accepted the STK push and sent the prompt to the customer
+func (r *STKPushRequestResponse) Accepted() bool {
+	return r.ErrorCode == "" && r.ResponseCode == ResponseCodeSuccess && r.CheckoutRequestID != ""
+}
+
+// Err returns the reason the STK push was not accepted, or nil when it was. Error envelopes are returned as an
+// *MpesaError, and so are responses with a ResponseCode other than "0".
+func (r *STKPushRequestResponse) Err() error {
+	switch {
+	case r.ErrorCode != "":
+		return &MpesaError{RequestID: r.RequestID, ErrorCode: r.ErrorCode, ErrorMessage: r.ErrorMessage}
+	case r.ResponseCode != ResponseCodeSuccess:
+		return &MpesaError{RequestID: r.MerchantRequestID, ErrorCode: r.ResponseCode, ErrorMessage: r.ResponseDescription}
+	case r.CheckoutRequestID == "":
+		return &MpesaError{
+			RequestID:    r.MerchantRequestID,
+			ErrorCode:    r.ResponseCode,
+			ErrorMessage: "the response has no CheckoutRequestID",
+		}
+	}
+
+	return nil
+}
+
+// CheckoutID returns the CheckoutRequestID identifying the STK push in the callback and status queries
+func (r *STKPushRequestResponse) CheckoutID() string {
+	return r.CheckoutRequestID
+}
+
+// IsSuccessful reports whether the customer completed the payment
+func (c *STKPushCallbackResponse) IsSuccessful() bool {
+	return c.Body.StkCallback.ResultCode == ResultCodeSuccess
+}
+
+// FailureReason returns the ResultDesc explaining why the payment failed, or "" when it was successful
+func (c *STKPushCallbackResponse) FailureReason() string {
+	if c.IsSuccessful() {
+		return ""
+	}
+
+	return c.Body.StkCallback.ResultDesc
+}
+
+// metadataItem returns the value of the STK callback metadata item with the given name
+func (c *STKPushCallbackResponse) metadataItem(name string) (interface{}, bool) {
+	for _, item := range c.Body.StkCallback.CallbackMetadata.Item {
+		if item.Name == name {
+			return item.Value, item.Value != nil
+		}
+	}
+
+	return nil, false
+}
+
+// metadataString returns the metadata item as a string. Numbers are formatted without an exponent, since
+// Safaricom sends phone numbers and dates as numbers.
+func (c *STKPushCallbackResponse) metadataString(name string) (string, bool) {
+	value, ok := c.metadataItem(name)
+	if !ok {
+		return "", false
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	}
+
+	return "", false
+}
+
+// Amount returns the amount the customer paid. It is only set on successful payments.
+func (c *STKPushCallbackResponse) Amount() (float64, bool) {
+	value, ok := c.metadataItem("Amount")
+	if !ok {
+		return 0, false
+	}
+
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		return n, err == nil
+	}
+
+	return 0, false
+}
+
+// MpesaReceiptNumber returns the receipt number of the payment, e.g. "NLJ7RT61SV"
+func (c *STKPushCallbackResponse) MpesaReceiptNumber() (string, bool) {
+	return c.metadataString("MpesaReceiptNumber")
+}
+
+// PhoneNumber returns the phone number that paid, e.g. "254708374149"
+func (c *STKPushCallbackResponse) PhoneNumber() (string, bool) {
+	return c.metadataString("PhoneNumber")
+}
+
+// TransactionDate returns when the payment was made, which Safaricom sends as a YYYYMMDDHHmmss number in Nairobi
+// time
+func (c *STKPushCallbackResponse) TransactionDate() (time.Time, bool) {
+	value, ok := c.metadataItem("TransactionDate")
+	if !ok {
+		return time.Time{}, false
+	}
+
+	date, err := ParseMpesaTimestamp(value)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return date, true
+}
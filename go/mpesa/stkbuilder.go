@@ -0,0 +1,80 @@
+package main
+
+import "time"
+
+// STKPushBuilder builds STK push request bodies from the fields that cannot be derived, computing the password,
+// the timestamp and the parties from them
+type STKPushBuilder struct {
+	config ShortcodeConfig
+	amount int
+	phone  string
+}
+
+// NewSTKPushBuilder returns a builder for paybill STK push requests
+func NewSTKPushBuilder() *STKPushBuilder {
+	return &STKPushBuilder{}
+}
+
+// Shortcode sets the paybill or store number the customer is charged into
+func (b *STKPushBuilder) Shortcode(shortcode string) *STKPushBuilder {
+	b.config.Shortcode = shortcode
+	return b
+}
+
+// Passkey sets the passkey of the shortcode the password is computed with
+func (b *STKPushBuilder) Passkey(passkey string) *STKPushBuilder {
+	b.config.Passkey = passkey
+	return b
+}
+
+// Amount sets the whole number of shillings charged
+func (b *STKPushBuilder) Amount(amount int) *STKPushBuilder {
+	b.amount = amount
+	return b
+}
+
+// Phone sets the phone number charged, in any format accepted by NormalizePhoneNumber
+func (b *STKPushBuilder) Phone(phone string) *STKPushBuilder {
+	b.phone = phone
+	return b
+}
+
+// AccountRef sets the AccountReference shown to the customer
+func (b *STKPushBuilder) AccountRef(ref string) *STKPushBuilder {
+	b.config.AccountReference = ref
+	return b
+}
+
+// Description sets the TransactionDesc
+func (b *STKPushBuilder) Description(desc string) *STKPushBuilder {
+	b.config.TransactionDesc = desc
+	return b
+}
+
+// CallbackURL sets the URL the result of the payment is posted to
+func (b *STKPushBuilder) CallbackURL(url string) *STKPushBuilder {
+	b.config.CallbackURL = url
+	return b
+}
+
+// TransactionType sets the type of the payment, STKCustomerPayBillOnline unless it is set
+func (b *STKPushBuilder) TransactionType(transactionType STKTransactionType) *STKPushBuilder {
+	b.config.TransactionType = transactionType
+	return b
+}
+
+// Till sets the till number paid by buy goods payments, where the shortcode is the store number
+func (b *STKPushBuilder) Till(till string) *STKPushBuilder {
+	b.config.PartyB = till
+	return b
+}
+
+// Build returns the request body. PartyA is the normalized phone number and PartyB the shortcode for paybills.
+func (b *STKPushBuilder) Build() (*STKPushRequestBody, error) {
+	phone, err := NormalizePhoneNumber(b.phone)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.config.stkPushRequestBody(b.amount, phone, time.Now())
+}
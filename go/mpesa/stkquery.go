@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// stkPushProcessingErrorCode is the error code of the envelope Safaricom answers status queries with while the
+// customer has not answered the prompt
+const stkPushProcessingErrorCode = "500.001.1001"
+
+const (
+	// defaultSTKPollInterval is the delay before the first status query of PollSTKPushStatus
+	defaultSTKPollInterval = 2 * time.Second
+	// maxSTKPollInterval caps the delay between the status queries of PollSTKPushStatus
+	maxSTKPollInterval = 30 * time.Second
+)
+
+// STKPushQueryRequestBody is the body of a request querying the status of an STK push
+type STKPushQueryRequestBody struct {
+	BusinessShortCode string `json:"BusinessShortCode"`
+	Password          string `json:"Password"`
+	Timestamp         string `json:"Timestamp"`
+	CheckoutRequestID string `json:"CheckoutRequestID"`
+}
+
+// STKPushQueryResponse is the status of an STK push. ResultCode and ResultDesc are the same as in the callback of
+// the STK push.
+type STKPushQueryResponse struct {
+	ResponseCode        string `json:"ResponseCode"`
+	ResponseDescription string `json:"ResponseDescription"`
+	MerchantRequestID   string `json:"MerchantRequestID"`
+	CheckoutRequestID   string `json:"CheckoutRequestID"`
+	ResultCode          string `json:"ResultCode"`
+	ResultDesc          string `json:"ResultDesc"`
+	RequestID           string `json:"requestId"`
+	ErrorCode           string `json:"errorCode"`
+	ErrorMessage        string `json:"errorMessage"`
+}
+
+// QuerySTKPushStatus queries the status of the STK push identified by body.CheckoutRequestID, for reconciling the
+// payments whose callback is late. While the customer has not answered the prompt Safaricom answers with an error
+// envelope, which is returned as an *MpesaError.
+func (m *Mpesa) QuerySTKPushStatus(body *STKPushQueryRequestBody) (*STKPushQueryResponse, error) {
+	return m.QuerySTKPushStatusWithContext(context.Background(), body)
+}
+
+// QuerySTKPushStatusWithContext is QuerySTKPushStatus with a context that cancels the request
+func (m *Mpesa) QuerySTKPushStatusWithContext(ctx context.Context, body *STKPushQueryRequestBody) (*STKPushQueryResponse, error) {
+	queryResponse := new(STKPushQueryResponse)
+	if _, err := m.send(ctx, stkQueryEndpoint, body, queryResponse); err != nil {
+		return nil, err
+	}
+
+	return queryResponse, nil
+}
+
+// PollSTKPushStatus queries the status of the STK push identified by checkoutRequestID, with the shortcode passed
+// to UseShortcode or MpesaOpts, until it has a result or ctx is done. The first query is made after interval,
+// which defaults to 2 seconds and doubles, up to 30 seconds, while the push is being processed.
+func (m *Mpesa) PollSTKPushStatus(ctx context.Context, checkoutRequestID string, interval time.Duration) (*STKPushQueryResponse, error) {
+	cfg, err := m.defaultShortcodeConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if interval <= 0 {
+		interval = defaultSTKPollInterval
+	}
+
+	for {
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("mpesa: STK push %s is still being processed: %w", checkoutRequestID, ctx.Err())
+		case <-timer.C:
+		}
+
+		password, timestamp := GenerateSTKPushPassword(cfg.Shortcode, cfg.Passkey)
+		queryResponse, err := m.QuerySTKPushStatusWithContext(ctx, &STKPushQueryRequestBody{
+			BusinessShortCode: cfg.Shortcode,
+			Password:          password,
+			Timestamp:         timestamp,
+			CheckoutRequestID: checkoutRequestID,
+		})
+
+		switch {
+		case err == nil && queryResponse.ResultCode != "":
+			return queryResponse, nil
+		case err != nil && !isSTKPushProcessing(err):
+			return nil, err
+		}
+
+		if interval *= 2; interval > maxSTKPollInterval {
+			interval = maxSTKPollInterval
+		}
+	}
+}
+
+// isSTKPushProcessing reports whether err is the envelope answering the status queries of the STK pushes the
+// customer has not answered yet
+func isSTKPushProcessing(err error) bool {
+	var mpesaErr *MpesaError
+	return errors.As(err, &mpesaErr) && mpesaErr.ErrorCode == stkPushProcessingErrorCode
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// unknownFields returns the top level keys of the JSON object in body that v, a pointer to a struct, does not
+// declare. Keys are matched case insensitively, as json.Unmarshal does. Nothing is returned for the bodies that are
+// not objects and for the types decoding themselves with UnmarshalJSON.
+func unknownFields(body []byte, v interface{}) []string {
+	if _, ok := v.(json.Unmarshaler); ok {
+		return nil
+	}
+
+	t := reflect.TypeOf(v)
+	if t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(body, &object); err != nil {
+		return nil
+	}
+
+	known := make(map[string]bool)
+	addJSONFieldNames(known, t.Elem())
+
+	var unknown []string
+	for key := range object {
+		if !known[strings.ToLower(key)] {
+			unknown = append(unknown, key)
+		}
+	}
+
+	sort.Strings(unknown)
+
+	return unknown
+}
+
+// addJSONFieldNames adds the lower cased JSON names of the fields of the struct type t to names, including those
+// of its embedded structs
+func addJSONFieldNames(names map[string]bool, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if name == "" && field.Anonymous {
+			fieldType := field.Type
+			if fieldType.Kind() == reflect.Pointer {
+				fieldType = fieldType.Elem()
+			}
+
+			if fieldType.Kind() == reflect.Struct {
+				addJSONFieldNames(names, fieldType)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		names[strings.ToLower(name)] = true
+	}
+}
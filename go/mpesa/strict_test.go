@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnknownFields(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		v    interface{}
+		want []string
+	}{
+		{
+			name: "known fields",
+			body: `{"MerchantRequestID":"1","CheckoutRequestID":"ws_CO_1","ResponseCode":"0"}`,
+			v:    new(STKPushRequestResponse),
+		},
+		{
+			name: "case insensitive",
+			body: `{"merchantrequestid":"1","RESPONSECODE":"0"}`,
+			v:    new(STKPushRequestResponse),
+		},
+		{
+			name: "several unknown fields",
+			body: `{"ResponseCode":"0","NewField":1,"AnotherField":"x"}`,
+			v:    new(STKPushRequestResponse),
+			want: []string{"AnotherField", "NewField"},
+		},
+		{
+			name: "not an object",
+			body: `[{"NewField":1}]`,
+			v:    new(STKPushRequestResponse),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unknownFields([]byte(tt.body), tt.v); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unknownFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrictDecodingLogsEveryUnknownField(t *testing.T) {
+	var logs bytes.Buffer
+	m := &Mpesa{strictDecoding: true, logger: slog.New(slog.NewTextHandler(&logs, nil))}
+
+	resp := new(STKPushRequestResponse)
+	if err := m.decodeResponse([]byte(`{"ResponseCode":"0","NewField":1,"AnotherField":"x"}`), resp); err != nil {
+		t.Fatalf("decodeResponse() error = %v", err)
+	}
+
+	if resp.ResponseCode != "0" {
+		t.Errorf("ResponseCode = %q, want %q", resp.ResponseCode, "0")
+	}
+
+	if got := logs.String(); !strings.Contains(got, "NewField") || !strings.Contains(got, "AnotherField") {
+		t.Errorf("logs = %q, want both unknown fields", got)
+	}
+}
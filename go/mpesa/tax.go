@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+// kraShortcode is the shortcode of the Kenya Revenue Authority, the PartyB of tax remittances
+const kraShortcode = "572572"
+
+// TaxRemittanceRequestBody is the body of a request remitting tax to KRA
+type TaxRemittanceRequestBody struct {
+	Initiator          string               `json:"Initiator"`
+	SecurityCredential string               `json:"SecurityCredential"`
+	CommandID          TaxRemittanceCommand `json:"CommandID"` // Defaults to TaxRemittancePayTaxToKRA
+	// SenderIdentifierType and RecieverIdentifierType are "4" for shortcodes. RecieverIdentifierType is spelled
+	// the way Safaricom expects it.
+	SenderIdentifierType   string `json:"SenderIdentifierType"`
+	RecieverIdentifierType string `json:"RecieverIdentifierType"`
+	Amount                 string `json:"Amount"` // Whole shillings, e.g. "10"
+	PartyA                 string `json:"PartyA"`
+	PartyB                 string `json:"PartyB"`           // Defaults to the KRA shortcode 572572
+	AccountReference       string `json:"AccountReference"` // The payment registration number issued by KRA
+	Remarks                string `json:"Remarks"`
+	QueueTimeOutURL        string `json:"QueueTimeOutURL"`
+	ResultURL              string `json:"ResultURL"`
+}
+
+// validate checks the tax remittance request body before it is sent
+func (b *TaxRemittanceRequestBody) validate(logger *slog.Logger) error {
+	if err := validateCommand("CommandID", b.CommandID, TaxRemittancePayTaxToKRA); err != nil {
+		return err
+	}
+
+	if b.AccountReference == "" {
+		return &ValidationError{Field: "AccountReference", Reason: "the KRA payment registration number is required"}
+	}
+
+	return validateResultURLs(b.ResultURL, b.QueueTimeOutURL, logger)
+}
+
+// RemitTax pays the tax in body.AccountReference to KRA from the shortcode in body.PartyA. The outcome is posted
+// to the ResultURL and decoded with TaxRemittanceResultCallback. The initiator and security credential
+// configured on the app are used when the body leaves them empty.
+func (m *Mpesa) RemitTax(body *TaxRemittanceRequestBody) (*AcknowledgementResponse, error) {
+	return m.RemitTaxWithContext(context.Background(), body)
+}
+
+// RemitTaxWithContext is RemitTax with a context that cancels the request
+func (m *Mpesa) RemitTaxWithContext(ctx context.Context, body *TaxRemittanceRequestBody) (*AcknowledgementResponse, error) {
+	taxBody := *body
+	if taxBody.CommandID == "" {
+		taxBody.CommandID = TaxRemittancePayTaxToKRA
+	}
+
+	if taxBody.PartyB == "" {
+		taxBody.PartyB = kraShortcode
+	}
+
+	var err error
+	if taxBody.Amount, err = taxRemittanceEndpoint.amount.normalize(taxBody.Amount); err != nil {
+		return nil, err
+	}
+
+	if err := m.fillInitiator(&taxBody.Initiator, &taxBody.SecurityCredential); err != nil {
+		return nil, err
+	}
+
+	if err := taxBody.validate(m.logger); err != nil {
+		return nil, err
+	}
+
+	ack := new(AcknowledgementResponse)
+	if _, err := m.send(ctx, taxRemittanceEndpoint, taxBody, ack); err != nil {
+		return nil, err
+	}
+
+	return ack, nil
+}
+
+// TaxRemittanceResultCallback is the outcome of a tax remittance posted to the ResultURL
+type TaxRemittanceResultCallback struct {
+	Result ResultEnvelope `json:"Result"`
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// mpesaTimestampLayout is the YYYYMMDDHHmmss layout of the timestamps in Safaricom requests and payloads
+const mpesaTimestampLayout = "20060102150405"
+
+// mpesaTimeZone is the Africa/Nairobi time zone the timestamps in Safaricom payloads are written in. East
+// Africa Time does not observe daylight saving, so a fixed zone stands in when the time zone database is missing.
+var mpesaTimeZone = nairobiLocation()
+
+func nairobiLocation() *time.Location {
+	location, err := time.LoadLocation("Africa/Nairobi")
+	if err != nil {
+		return time.FixedZone("EAT", 3*60*60)
+	}
+
+	return location
+}
+
+// ParseMpesaTimestamp parses a YYYYMMDDHHmmss timestamp in Nairobi time. Safaricom sends them as strings, such as
+// the TransTime of C2B payments, or as numbers, such as the TransactionDate of STK callbacks, and both are
+// accepted.
+func ParseMpesaTimestamp(v interface{}) (time.Time, error) {
+	var raw string
+
+	switch value := v.(type) {
+	case string:
+		raw = value
+	case float64:
+		raw = strconv.FormatFloat(value, 'f', -1, 64)
+	case int64:
+		raw = strconv.FormatInt(value, 10)
+	case int:
+		raw = strconv.Itoa(value)
+	default:
+		return time.Time{}, fmt.Errorf("mpesa: invalid timestamp %v of type %T", v, v)
+	}
+
+	timestamp, err := time.ParseInLocation(mpesaTimestampLayout, raw, mpesaTimeZone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("mpesa: invalid timestamp %q: %w", raw, err)
+	}
+
+	return timestamp, nil
+}
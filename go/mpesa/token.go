@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// tokenRefreshMargin is how long before its expiry a cached access token is replaced by a new one. It is capped
+// at a quarter of the lifetime of the tokens, so that short lived ones are still cached.
+const tokenRefreshMargin = 60 * time.Second
+
+// tokenRefresherRetryDelay is how long the background token refresher waits after a failed refresh
+const tokenRefresherRetryDelay = 10 * time.Second
+
+// AccessToken is an access token with the metadata Safaricom returned with it
+type AccessToken struct {
+	Token     string
+	ExpiresIn time.Duration
+	// ExpiresAt is when the token stops being valid, computed from ExpiresIn and the time it was generated. It
+	// is zero when Safaricom did not say when the token expires.
+	ExpiresAt time.Time
+	// Scope is empty unless Safaricom starts returning one
+	Scope string
+	// Cached is set when the token was reused rather than generated for this call
+	Cached bool
+}
+
+// AccessToken returns the access token the app authenticates with, and its metadata, for applications that
+// manage the tokens outside the app. The cached token is returned while it is valid.
+func (m *Mpesa) AccessToken(ctx context.Context) (*AccessToken, error) {
+	return m.getAccessToken(ctx)
+}
+
+// TokenInfo returns the access token in the token store and when it expires, without generating one. valid is
+// false when there is no token or it has expired, it stays true during the last minute in which the next
+// request replaces the token. It is safe to call concurrently with requests.
+func (m *Mpesa) TokenInfo() (token string, expiresAt time.Time, valid bool) {
+	token, expiresAt, ok := m.tokens.Get(context.Background())
+	if !ok || !time.Now().Before(expiresAt) {
+		return "", time.Time{}, false
+	}
+
+	return token, expiresAt, true
+}
+
+// tokenRefresh is an access token request in flight, shared by the callers that find the cached token expired
+type tokenRefresh struct {
+	done  chan struct{}
+	token *AccessToken
+	err   error
+}
+
+// getAccessToken returns the access token in the token store, generating a new one when there is none or it is
+// about to expire. Concurrent callers wait for a single request instead of each generating their own token.
+func (m *Mpesa) getAccessToken(ctx context.Context) (*AccessToken, error) {
+	for {
+		if token, ok := m.storedAccessToken(ctx); ok {
+			m.logger.DebugContext(ctx, "mpesa: access token", "source", "cache", "expires_at", token.ExpiresAt)
+			return token, nil
+		}
+
+		m.mu.Lock()
+
+		refresh := m.tokenRefresh
+		if refresh == nil {
+			refresh = &tokenRefresh{done: make(chan struct{})}
+			m.tokenRefresh = refresh
+			m.mu.Unlock()
+
+			return m.refreshAccessToken(ctx, refresh)
+		}
+
+		m.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-refresh.done:
+		}
+
+		// The request was made with the context of another caller, try again if that context is what failed it
+		if errors.Is(refresh.err, context.Canceled) || errors.Is(refresh.err, context.DeadlineExceeded) {
+			continue
+		}
+
+		return refresh.token, refresh.err
+	}
+}
+
+// refreshAccessToken generates the access token for refresh, stores it and wakes up the callers waiting for it
+func (m *Mpesa) refreshAccessToken(ctx context.Context, refresh *tokenRefresh) (*AccessToken, error) {
+	refresh.token, refresh.err = m.fetchAccessToken(ctx)
+
+	// A token without an expiry cannot be stored, it is used by the callers of this refresh only
+	if refresh.err == nil && !refresh.token.ExpiresAt.IsZero() {
+		m.tokenLifetime.Store(int64(refresh.token.ExpiresIn))
+		m.tokens.Set(ctx, refresh.token.Token, refresh.token.ExpiresAt)
+
+		if m.autoRefreshToken {
+			m.startTokenRefresher(refresh.token.ExpiresAt)
+		}
+	}
+
+	m.mu.Lock()
+	m.tokenRefresh = nil
+	m.mu.Unlock()
+
+	close(refresh.done)
+
+	if refresh.err != nil {
+		return nil, refresh.err
+	}
+
+	m.logger.DebugContext(ctx, "mpesa: access token", "source", "network", "expires_at", refresh.token.ExpiresAt)
+
+	return refresh.token, nil
+}
+
+// tokenRefresher is the goroutine of MpesaOpts.AutoRefreshToken
+type tokenRefresher struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// stop cancels the refresher and waits for its goroutine to return
+func (r *tokenRefresher) stop() {
+	r.cancel()
+	<-r.done
+}
+
+// startTokenRefresher starts the background token refresher unless it is running or the app is closed
+func (m *Mpesa) startTokenRefresher(expiresAt time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.tokenRefresher != nil || m.closed {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	refresher := &tokenRefresher{cancel: cancel, done: make(chan struct{})}
+	m.tokenRefresher = refresher
+
+	go func() {
+		defer close(refresher.done)
+		m.refreshTokens(ctx, expiresAt)
+	}()
+}
+
+// refreshMargin returns how long before its expiry the cached access token is replaced, from the lifetime of the
+// last token the app generated. The full margin applies until a token has been generated.
+func (m *Mpesa) refreshMargin() time.Duration {
+	if lifetime := time.Duration(m.tokenLifetime.Load()); lifetime > 0 && lifetime/4 < tokenRefreshMargin {
+		return lifetime / 4
+	}
+
+	return tokenRefreshMargin
+}
+
+// refresherWait returns how long the token refresher waits before replacing the token expiring at expiresAt. It
+// waits at least tokenRefresherRetryDelay, so that tokens expiring right away are not requested in a loop.
+func (m *Mpesa) refresherWait(expiresAt time.Time) time.Duration {
+	if wait := time.Until(expiresAt) - m.refreshMargin(); wait > tokenRefresherRetryDelay {
+		return wait
+	}
+
+	return tokenRefresherRetryDelay
+}
+
+// refreshTokens generates a new access token when the current one, expiring at expiresAt, is about to expire,
+// until ctx is done. The tokens are generated through getAccessToken, so a request finding the token expired at
+// the same time waits for the same refresh.
+func (m *Mpesa) refreshTokens(ctx context.Context, expiresAt time.Time) {
+	wait := m.refresherWait(expiresAt)
+
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		token, err := m.getAccessToken(ctx)
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			m.logger.Warn("mpesa: background access token refresh failed", "error", err)
+			wait = tokenRefresherRetryDelay
+		case token.ExpiresAt.IsZero():
+			// Safaricom did not say when the token expires, it is generated again by the requests needing one
+			return
+		default:
+			wait = m.refresherWait(token.ExpiresAt)
+		}
+	}
+}
+
+// storedAccessToken returns the token in the token store if it is not about to expire. Only the token and its
+// expiry are stored, the other fields of the returned token are left empty.
+func (m *Mpesa) storedAccessToken(ctx context.Context) (*AccessToken, bool) {
+	token, expiry, ok := m.tokens.Get(ctx)
+	if !ok || time.Now().Add(m.refreshMargin()).After(expiry) {
+		return nil, false
+	}
+
+	return &AccessToken{Token: token, ExpiresIn: time.Until(expiry), ExpiresAt: expiry, Cached: true}, true
+}
+
+// fetchAccessToken generates a new access token and computes its expiry
+func (m *Mpesa) fetchAccessToken(ctx context.Context) (*AccessToken, error) {
+	generatedAt := time.Now()
+
+	resp, err := m.generateAccessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &AccessToken{Token: resp.AccessToken, Scope: resp.Scope}
+
+	if resp.ExpiresIn == "" {
+		return token, nil
+	}
+
+	if token.ExpiresIn, err = resp.ExpiresInDuration(); err != nil {
+		return nil, err
+	}
+
+	token.ExpiresAt = generatedAt.Add(token.ExpiresIn)
+
+	return token, nil
+}
+
+// ExpiresInDuration returns how long the token is valid for. An error is returned when Safaricom did not say, or
+// sent something other than a number of seconds.
+func (r *MpesaAccessTokenResponse) ExpiresInDuration() (time.Duration, error) {
+	if r.ExpiresIn == "" {
+		return 0, errors.New("mpesa: the token response has no expires_in")
+	}
+
+	seconds, err := strconv.Atoi(r.ExpiresIn)
+	if err != nil || seconds < 0 {
+		return 0, fmt.Errorf("mpesa: invalid token expires_in %q", r.ExpiresIn)
+	}
+
+	return time.Duration(seconds) * time.Second, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAccessTokenRejectsUnusableResponses(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{name: "empty 500", status: http.StatusInternalServerError},
+		{name: "empty object 503", status: http.StatusServiceUnavailable, body: `{}`},
+		{name: "200 without access_token", status: http.StatusOK, body: `{"expires_in":"3599"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewMockServer()
+			defer s.Close()
+
+			s.Respond(oauthEndpoint.path, tt.status, tt.body)
+			m := newTestMpesa(t, s, MpesaOpts{})
+
+			if _, err := m.AccessToken(context.Background()); !errors.Is(err, ErrServiceUnavailable) {
+				t.Fatalf("AccessToken() error = %v, want ErrServiceUnavailable", err)
+			}
+
+			if token, _, valid := m.TokenInfo(); valid {
+				t.Errorf("TokenInfo() = %q, the failed response must not be cached", token)
+			}
+		})
+	}
+}
+
+func TestShortLivedTokenIsCachedAndRefreshedOnce(t *testing.T) {
+	s := NewMockServer()
+	defer s.Close()
+
+	s.Respond(oauthEndpoint.path, http.StatusOK, `{"access_token":"mock-access-token","expires_in":"30"}`)
+	m := newTestMpesa(t, s, MpesaOpts{AutoRefreshToken: true})
+
+	if _, err := m.AccessToken(context.Background()); err != nil {
+		t.Fatalf("AccessToken() error = %v", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	token, err := m.AccessToken(context.Background())
+	if err != nil {
+		t.Fatalf("AccessToken() error = %v", err)
+	}
+
+	if !token.Cached {
+		t.Error("AccessToken() generated a new token, want the cached one")
+	}
+
+	if got := s.Requests(oauthEndpoint.path); got != 1 {
+		t.Errorf("oauth requests = %d, want 1", got)
+	}
+}
+
+func TestCloseKeepsTheStoredToken(t *testing.T) {
+	s := NewMockServer()
+	defer s.Close()
+
+	store := NewMemoryTokenStore()
+	m := newTestMpesa(t, s, MpesaOpts{TokenStore: store})
+
+	if _, err := m.AccessToken(context.Background()); err != nil {
+		t.Fatalf("AccessToken() error = %v", err)
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if token, _, ok := store.Get(context.Background()); !ok || token != "mock-access-token" {
+		t.Errorf("store.Get() = %q, %v, want the token shared with the other processes", token, ok)
+	}
+}
+
+func TestAccessTokenReportsCacheHits(t *testing.T) {
+	s := NewMockServer()
+	defer s.Close()
+
+	m := newTestMpesa(t, s, MpesaOpts{})
+
+	for i, wantCached := range []bool{false, true} {
+		token, err := m.AccessToken(context.Background())
+		if err != nil {
+			t.Fatalf("AccessToken() error = %v", err)
+		}
+
+		if token.Cached != wantCached {
+			t.Errorf("call %d: Cached = %v, want %v", i+1, token.Cached, wantCached)
+		}
+	}
+}
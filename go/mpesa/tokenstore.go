@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TokenStore keeps the access token shared by the apps using it, so that several processes authenticating with
+// the same consumer key can reuse one token instead of each generating their own. Implementations must be safe
+// for concurrent use.
+type TokenStore interface {
+	// Get returns the stored token and when it expires, ok is false when no token is stored
+	Get(ctx context.Context) (token string, expiry time.Time, ok bool)
+	// Set stores the token until its expiry
+	Set(ctx context.Context, token string, expiry time.Time)
+}
+
+// MemoryTokenStore is a TokenStore keeping the token in memory, the default of the app
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// NewMemoryTokenStore returns an empty MemoryTokenStore
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{}
+}
+
+// Get returns the stored token if it has not expired
+func (s *MemoryTokenStore) Get(_ context.Context) (string, time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == "" || !time.Now().Before(s.expiry) {
+		return "", time.Time{}, false
+	}
+
+	return s.token, s.expiry, true
+}
+
+// Set stores the token until its expiry, replacing the previous one
+func (s *MemoryTokenStore) Set(_ context.Context, token string, expiry time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token, s.expiry = token, expiry
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+)
+
+// TransactionStatusRequestBody is the body of a request querying the status of a transaction
+type TransactionStatusRequestBody struct {
+	Initiator          string                   `json:"Initiator"`
+	SecurityCredential string                   `json:"SecurityCredential"`
+	CommandID          TransactionStatusCommand `json:"CommandID"` // Defaults to TransactionStatusQuery
+	TransactionID      string                   `json:"TransactionID"`
+	PartyA             string                   `json:"PartyA"`
+	IdentifierType     string                   `json:"IdentifierType"` // "4" for a shortcode, "1" for a phone number
+	ResultURL          string                   `json:"ResultURL"`
+	QueueTimeOutURL    string                   `json:"QueueTimeOutURL"`
+	Remarks            string                   `json:"Remarks"`
+	Occasion           string                   `json:"Occasion,omitempty"`
+}
+
+// validate checks the transaction status request body before it is sent
+func (b *TransactionStatusRequestBody) validate(logger *slog.Logger) error {
+	if err := validateCommand("CommandID", b.CommandID, TransactionStatusQuery); err != nil {
+		return err
+	}
+
+	if b.TransactionID == "" {
+		return &ValidationError{Field: "TransactionID", Reason: "must not be empty"}
+	}
+
+	return validateResultURLs(b.ResultURL, b.QueueTimeOutURL, logger)
+}
+
+// CheckTransactionStatus requests the status of the transaction in body.TransactionID, which is posted to the
+// ResultURL and decoded with TransactionStatusResultCallback. The initiator and security credential configured
+// on the app are used when the body leaves them empty.
+func (m *Mpesa) CheckTransactionStatus(body *TransactionStatusRequestBody) (*AcknowledgementResponse, error) {
+	return m.CheckTransactionStatusWithContext(context.Background(), body)
+}
+
+// CheckTransactionStatusWithContext is CheckTransactionStatus with a context that cancels the request
+func (m *Mpesa) CheckTransactionStatusWithContext(ctx context.Context, body *TransactionStatusRequestBody) (*AcknowledgementResponse, error) {
+	statusBody := *body
+	if statusBody.CommandID == "" {
+		statusBody.CommandID = TransactionStatusQuery
+	}
+
+	if err := m.fillInitiator(&statusBody.Initiator, &statusBody.SecurityCredential); err != nil {
+		return nil, err
+	}
+
+	if err := statusBody.validate(m.logger); err != nil {
+		return nil, err
+	}
+
+	ack := new(AcknowledgementResponse)
+	if _, err := m.send(ctx, transactionStatusEndpoint, statusBody, ack); err != nil {
+		return nil, err
+	}
+
+	return ack, nil
+}
+
+// TransactionStatusResultCallback is the result of a transaction status query posted to the ResultURL. The
+// details of the transaction, such as "ReceiptNo", "Amount" and "TransactionStatus", are result parameters.
+type TransactionStatusResultCallback struct {
+	Result ResultEnvelope `json:"Result"`
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	// defaultTimeout is the limit for a whole request, response body included
+	defaultTimeout = 10 * time.Second
+	// defaultConnectTimeout is the limit for resolving Safaricom's hosts and connecting to them
+	defaultConnectTimeout = 5 * time.Second
+	// defaultTLSHandshakeTimeout is the limit for the TLS handshake once connected
+	defaultTLSHandshakeTimeout = 5 * time.Second
+)
+
+// orDefault returns d, or fallback when d is not positive
+func orDefault(d, fallback time.Duration) time.Duration {
+	if d <= 0 {
+		return fallback
+	}
+
+	return d
+}
+
+// newHTTPClient returns the http client of the app with the connection timeouts configured in the options.
+// Connecting and the TLS handshake are limited separately from the whole request, so a stuck connection fails on
+// its own timeout as a *net.OpError with the "dial" Op instead of hitting the request timeout. The whole request
+// is limited by sendRequest, so that the deadline of the caller can take precedence.
+func newHTTPClient(m *MpesaOpts) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   orDefault(m.ConnectTimeout, defaultConnectTimeout),
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = orDefault(m.TLSHandshakeTimeout, defaultTLSHandshakeTimeout)
+
+	return &http.Client{Transport: transport}
+}
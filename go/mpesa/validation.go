@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ValidationError is returned when a request body fails the checks performed before it is sent to Safaricom
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("mpesa: invalid %s: %s", e.Field, e.Reason)
+}
+
+// transactionDescPunctuation lists the punctuation Safaricom accepts in TransactionDesc besides letters,
+// digits and spaces.
+const transactionDescPunctuation = ".,-_/#:"
+
+// isTransactionDescRune reports whether r is allowed in a TransactionDesc
+func isTransactionDescRune(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == ' ':
+		return true
+	}
+
+	return strings.ContainsRune(transactionDescPunctuation, r)
+}
+
+// ValidateTransactionDesc checks that desc only contains characters Safaricom accepts in TransactionDesc.
+func ValidateTransactionDesc(desc string) error {
+	if strings.TrimSpace(desc) == "" {
+		return &ValidationError{Field: "TransactionDesc", Reason: "must not be empty"}
+	}
+
+	for _, r := range desc {
+		if !isTransactionDescRune(r) {
+			return &ValidationError{
+				Field: "TransactionDesc",
+				Reason: fmt.Sprintf(
+					"character %q is not allowed, use letters, digits, spaces or %q", r, transactionDescPunctuation,
+				),
+			}
+		}
+	}
+
+	return nil
+}
+
+// SanitizeTransactionDesc strips the characters Safaricom rejects from desc and collapses the remaining whitespace.
+func SanitizeTransactionDesc(desc string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		if isTransactionDescRune(r) {
+			return r
+		}
+		return ' '
+	}, desc)
+
+	return strings.Join(strings.Fields(cleaned), " ")
+}
+
+// validate checks the STK push request body before it is sent. When sanitize is set, the disallowed characters
+// in TransactionDesc are stripped instead of being reported.
+func (b *STKPushRequestBody) validate(sanitize bool) error {
+	if err := validateCommand("TransactionType", b.TransactionType, STKCustomerPayBillOnline, STKCustomerBuyGoodsOnline); err != nil {
+		return err
+	}
+
+	if err := b.validatePassword(); err != nil {
+		return err
+	}
+
+	if err := b.validateParties(); err != nil {
+		return err
+	}
+
+	// Safaricom accepts STK pushes with a bad CallBackURL but never delivers their callback
+	if err := validateHTTPSURL("CallBackURL", b.CallBackURL); err != nil {
+		return err
+	}
+
+	if sanitize {
+		b.TransactionDesc = SanitizeTransactionDesc(b.TransactionDesc)
+	}
+
+	return ValidateTransactionDesc(b.TransactionDesc)
+}
+
+// validatePassword checks that BusinessShortCode is a shortcode, that Timestamp is a timestamp and that Password
+// was generated for them, since Safaricom only answers a mismatch with an obscure "invalid password" error. Passwords copied
+// from another shortcode or sent with a new timestamp are the usual culprits.
+func (b *STKPushRequestBody) validatePassword() error {
+	if b.BusinessShortCode == "" || strings.Trim(b.BusinessShortCode, "0123456789") != "" {
+		return &ValidationError{Field: "BusinessShortCode", Reason: fmt.Sprintf("%q is not a shortcode", b.BusinessShortCode)}
+	}
+
+	if _, err := time.Parse(mpesaTimestampLayout, b.Timestamp); err != nil || len(b.Timestamp) != len(mpesaTimestampLayout) {
+		return &ValidationError{Field: "Timestamp", Reason: fmt.Sprintf("%q is not a YYYYMMDDHHmmss timestamp", b.Timestamp)}
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(b.Password)
+	if err != nil {
+		return &ValidationError{Field: "Password", Reason: "is not base64 encoded, generate it with GenerateSTKPushPassword"}
+	}
+
+	password := string(decoded)
+	if !strings.HasPrefix(password, b.BusinessShortCode) || !strings.HasSuffix(password, b.Timestamp) ||
+		len(password) <= len(b.BusinessShortCode)+len(b.Timestamp) {
+		return &ValidationError{
+			Field: "Password",
+			Reason: fmt.Sprintf(
+				"password/shortcode/timestamp mismatch, it was not generated for the BusinessShortCode %q and Timestamp %q",
+				b.BusinessShortCode, b.Timestamp,
+			),
+		}
+	}
+
+	return nil
+}
+
+// validateParties rejects the combinations of BusinessShortCode, PartyB and TransactionType that
+// Safaricom refuses. Paybill payments go to the shortcode itself, while buy goods payments go to a till that is
+// different from the store number in BusinessShortCode.
+func (b *STKPushRequestBody) validateParties() error {
+	switch b.TransactionType {
+	case STKCustomerPayBillOnline:
+		if b.PartyB != b.BusinessShortCode {
+			return &ValidationError{
+				Field: "PartyB",
+				Reason: fmt.Sprintf(
+					"%q must be the BusinessShortCode %q for %s", b.PartyB, b.BusinessShortCode, b.TransactionType,
+				),
+			}
+		}
+	case STKCustomerBuyGoodsOnline:
+		if b.PartyB == "" || b.PartyB == b.BusinessShortCode {
+			return &ValidationError{
+				Field: "PartyB",
+				Reason: fmt.Sprintf(
+					"must be the till number for %s, not the BusinessShortCode %q", b.TransactionType, b.BusinessShortCode,
+				),
+			}
+		}
+	}
+
+	return nil
+}
+
+// validate checks the B2C request body before it is sent
+func (b *B2CRequestBody) validate(logger *slog.Logger) error {
+	if err := validateCommand("CommandID", b.CommandID, B2CBusinessPayment, B2CSalaryPayment, B2CPromotionPayment); err != nil {
+		return err
+	}
+
+	return validateResultURLs(b.ResultURL, b.QueueTimeOutURL, logger)
+}
+
+// validateHTTPSURL checks that the field holds an absolute https URL
+func validateHTTPSURL(field, raw string) error {
+	if raw == "" {
+		return &ValidationError{Field: field, Reason: "must not be empty"}
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return &ValidationError{Field: field, Reason: fmt.Sprintf("%q is not an absolute https URL", raw)}
+	}
+
+	return nil
+}
+
+// validateResultURLs checks the URLs the asynchronous APIs post their results and timeouts to. Sharing one URL
+// makes the timeouts land on the result handler, which is allowed but logged as a warning.
+func validateResultURLs(resultURL, queueTimeOutURL string, logger *slog.Logger) error {
+	if err := validateHTTPSURL("ResultURL", resultURL); err != nil {
+		return err
+	}
+
+	if err := validateHTTPSURL("QueueTimeOutURL", queueTimeOutURL); err != nil {
+		return err
+	}
+
+	if resultURL == queueTimeOutURL {
+		logger.Warn("mpesa: ResultURL and QueueTimeOutURL are the same, timeouts will reach the result handler", "url", resultURL)
+	}
+
+	return nil
+}
+
+// ValidateBillRefNumber checks the BillRefNumber of a C2B payment. Paybill payments must carry the account number,
+// and when pattern is not nil it must match it. Buy goods payments go to a till and must not carry one.
+func ValidateBillRefNumber(command C2BCommand, billRefNumber string, pattern *regexp.Regexp) error {
+	switch command {
+	case C2BCustomerPayBillOnline:
+		if strings.TrimSpace(billRefNumber) == "" {
+			return &ValidationError{Field: "BillRefNumber", Reason: "the account number is required for paybill payments"}
+		}
+
+		if pattern != nil && !pattern.MatchString(billRefNumber) {
+			return &ValidationError{
+				Field:  "BillRefNumber",
+				Reason: fmt.Sprintf("%q does not match the account number format %s", billRefNumber, pattern),
+			}
+		}
+	case C2BCustomerBuyGoodsOnline:
+		if billRefNumber != "" {
+			return &ValidationError{Field: "BillRefNumber", Reason: "must be empty for buy goods payments"}
+		}
+	default:
+		return validateCommand("CommandID", command, C2BCustomerPayBillOnline, C2BCustomerBuyGoodsOnline)
+	}
+
+	return nil
+}
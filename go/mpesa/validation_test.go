@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+// validSTKPushBody returns an STK push body with a password generated for its shortcode
+func validSTKPushBody() *STKPushRequestBody {
+	body := testSTKPushBody()
+	body.BusinessShortCode = "174379"
+	body.Password, body.Timestamp = GenerateSTKPushPassword(body.BusinessShortCode, "mock-passkey")
+	body.CallBackURL = "https://example.com/mpesa/stk"
+
+	return body
+}
+
+func TestSTKPushRequestBodyValidateParties(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(b *STKPushRequestBody)
+		wantField string
+	}{
+		{name: "paybill"},
+		{name: "PartyA other than PhoneNumber", modify: func(b *STKPushRequestBody) { b.PartyA = "254700000000" }},
+		{name: "paybill to another shortcode", modify: func(b *STKPushRequestBody) { b.PartyB = "600000" }, wantField: "PartyB"},
+		{name: "buy goods to a till", modify: func(b *STKPushRequestBody) {
+			b.TransactionType = STKCustomerBuyGoodsOnline
+			b.PartyB = "600000"
+		}},
+		{name: "buy goods to the store number", modify: func(b *STKPushRequestBody) {
+			b.TransactionType = STKCustomerBuyGoodsOnline
+		}, wantField: "PartyB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := validSTKPushBody()
+			if tt.modify != nil {
+				tt.modify(body)
+			}
+
+			assertValidationField(t, body.validate(false), tt.wantField)
+		})
+	}
+}
+
+// assertValidationField checks that err is nil when field is empty, and a ValidationError of field otherwise
+func assertValidationField(t *testing.T, err error, field string) {
+	t.Helper()
+
+	if field == "" {
+		if err != nil {
+			t.Fatalf("validate() error = %v", err)
+		}
+
+		return
+	}
+
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Field != field {
+		t.Fatalf("validate() error = %v, want a ValidationError of %s", err, field)
+	}
+}
+
+func TestSTKPushRequestBodyValidatePassword(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(b *STKPushRequestBody)
+		wantField string
+	}{
+		{name: "generated password"},
+		{name: "empty Timestamp", modify: func(b *STKPushRequestBody) {
+			b.Password = base64.StdEncoding.EncodeToString([]byte(b.BusinessShortCode + "mock-passkey"))
+			b.Timestamp = ""
+		}, wantField: "Timestamp"},
+		{name: "short Timestamp", modify: func(b *STKPushRequestBody) { b.Timestamp = b.Timestamp[:12] }, wantField: "Timestamp"},
+		{name: "other Timestamp", modify: func(b *STKPushRequestBody) { b.Timestamp = "20200101000000" }, wantField: "Password"},
+		{name: "other shortcode", modify: func(b *STKPushRequestBody) {
+			b.BusinessShortCode, b.PartyB = "600000", "600000"
+		}, wantField: "Password"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := validSTKPushBody()
+			if tt.modify != nil {
+				tt.modify(body)
+			}
+
+			assertValidationField(t, body.validate(false), tt.wantField)
+		})
+	}
+}